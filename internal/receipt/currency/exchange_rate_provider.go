@@ -0,0 +1,103 @@
+package currency
+
+import (
+	"fmt"
+	"math"
+	"receipt-processor-challenge/internal/receipt/model"
+	"sync"
+)
+
+type ExchangeRateProvider interface {
+	Rate(from string, to string) (Rate, error)
+}
+
+var (
+	defaultProvider   ExchangeRateProvider = NewStaticProvider(DefaultCurrency, nil)
+	defaultProviderMu sync.RWMutex
+)
+
+// Function to set the provider receipts are converted to the default currency with
+func SetDefaultProvider(provider ExchangeRateProvider) {
+	defaultProviderMu.Lock()
+	defer defaultProviderMu.Unlock()
+	defaultProvider = provider
+}
+
+// Function to get the provider receipts are converted to the default currency with
+func DefaultProvider() ExchangeRateProvider {
+	defaultProviderMu.RLock()
+	defer defaultProviderMu.RUnlock()
+	return defaultProvider
+}
+
+// Function to check if a code is a known ISO-4217 currency code the default provider has a rate to the default currency for,
+// so receipts in it can be converted before they are scored
+func IsSupportedCurrency(code string) bool {
+	if !IsKnownCurrency(code) {
+		return false
+	}
+	_, err := DefaultProvider().Rate(code, DefaultCurrency)
+	return err == nil
+}
+
+// an exchange rate stored in millionths so conversions stay exact to six decimal places
+type Rate struct {
+	micros int64
+}
+
+const microsPerUnit = 1_000_000
+
+// Function to create a new Rate from a decimal value such as 0.73
+func NewRate(value float64) (Rate, error) {
+	if value <= 0 || math.IsInf(value, 0) || math.IsNaN(value) {
+		return Rate{}, fmt.Errorf("exchange rate must be a positive number but got %v", value)
+	}
+	micros := int64(math.Round(value * microsPerUnit))
+	if micros == 0 {
+		return Rate{}, fmt.Errorf("exchange rate %v is smaller than 0.000001", value)
+	}
+	return Rate{micros: micros}, nil
+}
+
+// the rate that converts an amount to itself
+func IdentityRate() Rate {
+	return Rate{micros: microsPerUnit}
+}
+
+// divides one rate by another, used to cross two rates through a shared base currency
+func (r Rate) Div(other Rate) Rate {
+	return Rate{micros: roundedDiv(r.micros*microsPerUnit, other.micros)}
+}
+
+func (r Rate) String() string {
+	return fmt.Sprintf("%d.%06d", r.micros/microsPerUnit, r.micros%microsPerUnit)
+}
+
+// Function to convert an amount with an exchange rate, rounding half away from zero to the nearest cent
+func Convert(amount model.Money, rate Rate) model.Money {
+	if !amount.IsValid() {
+		return amount
+	}
+	return model.NewMoney(roundedDiv(amount.Cents()*rate.micros, microsPerUnit))
+}
+
+// Function to divide two integers rounding half away from zero
+func roundedDiv(dividend int64, divisor int64) int64 {
+	quotient := dividend / divisor
+	remainder := dividend % divisor
+	if remainder < 0 {
+		remainder = -remainder
+	}
+	absoluteDivisor := divisor
+	if absoluteDivisor < 0 {
+		absoluteDivisor = -absoluteDivisor
+	}
+	if 2*remainder >= absoluteDivisor {
+		if (dividend < 0) != (divisor < 0) {
+			quotient--
+		} else {
+			quotient++
+		}
+	}
+	return quotient
+}
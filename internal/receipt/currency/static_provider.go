@@ -0,0 +1,71 @@
+package currency
+
+import (
+	"fmt"
+	"receipt-processor-challenge/pkg/config"
+	"strings"
+)
+
+type StaticProvider struct {
+	base  string
+	rates map[string]Rate
+}
+
+// Function to create a new static exchange rate provider, rates are the value of one unit of each currency in the base currency
+func NewStaticProvider(base string, rates map[string]Rate) *StaticProvider {
+	providerRates := make(map[string]Rate, len(rates)+1)
+	for code, rate := range rates {
+		providerRates[strings.ToUpper(code)] = rate
+	}
+	providerRates[base] = IdentityRate()
+	return &StaticProvider{
+		base:  base,
+		rates: providerRates,
+	}
+}
+
+// Function to create a static exchange rate provider from an exchange rate file
+func LoadStaticProvider(path string) (*StaticProvider, error) {
+	exchangeRatesConfig, err := config.LoadExchangeRates(path)
+	if err != nil {
+		return nil, err
+	}
+
+	base := strings.ToUpper(exchangeRatesConfig.Base)
+	if base == "" {
+		base = DefaultCurrency
+	}
+	if !IsKnownCurrency(base) {
+		return nil, fmt.Errorf("base currency %s in %s is not a known currency", base, path)
+	}
+
+	rates := make(map[string]Rate, len(exchangeRatesConfig.Rates))
+	for code, value := range exchangeRatesConfig.Rates {
+		code = strings.ToUpper(code)
+		if !IsKnownCurrency(code) {
+			return nil, fmt.Errorf("currency %s in %s is not a known currency", code, path)
+		}
+		rate, err := NewRate(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rate for currency %s in %s: %w", code, path, err)
+		}
+		rates[code] = rate
+	}
+	return NewStaticProvider(base, rates), nil
+}
+
+// gets the rate that converts an amount in one currency to another by going through the base currency
+func (provider *StaticProvider) Rate(from string, to string) (Rate, error) {
+	if from == to {
+		return IdentityRate(), nil
+	}
+	fromRate, exists := provider.rates[from]
+	if !exists {
+		return Rate{}, fmt.Errorf("no exchange rate from %s to %s", from, provider.base)
+	}
+	toRate, exists := provider.rates[to]
+	if !exists {
+		return Rate{}, fmt.Errorf("no exchange rate from %s to %s", to, provider.base)
+	}
+	return fromRate.Div(toRate), nil
+}
@@ -0,0 +1,120 @@
+package fraud
+
+import (
+	"fmt"
+	"math"
+	"receipt-processor-challenge/internal/receipt/model"
+	"strings"
+	"time"
+)
+
+// score at which receipts are flagged for review when no threshold is configured
+const DefaultThreshold = 0.8
+
+// how much each signal counts towards the similarity of two receipts, the weights add up to 1
+const (
+	timeWeight  = 0.2
+	totalWeight = 0.4
+	itemsWeight = 0.4
+)
+
+// purchase times further apart than this aren't considered similar at all
+const timeWindow = 60 * time.Minute
+
+type Scorer struct {
+	threshold float64
+}
+
+// Function to create a new fraud scorer that flags receipts scoring at least the threshold, a score from 0 to 1
+func NewScorer(threshold float64) (*Scorer, error) {
+	if threshold <= 0 || threshold > 1 {
+		return nil, fmt.Errorf("fraud review threshold must be greater than 0 and at most 1 but got %v", threshold)
+	}
+	return &Scorer{threshold: threshold}, nil
+}
+
+// reviews a receipt against recent receipts from the same retailer and date. The score is the similarity to the most similar
+// receipt, based on how close the purchase times and totals are and how many items the receipts have in common
+func (scorer *Scorer) Review(receipt *model.Receipt, recentReceipts []model.ProcessedReceipt) model.FraudReview {
+	bestScore := 0.0
+	bestReceiptId := ""
+	var bestReasons []string
+	for _, recentReceipt := range recentReceipts {
+		score, reasons := similarity(receipt, recentReceipt.Receipt())
+		if score > bestScore {
+			bestScore = score
+			bestReceiptId = recentReceipt.ID()
+			bestReasons = reasons
+		}
+	}
+	return model.NewFraudReview(math.Round(bestScore*100)/100, scorer.threshold, bestReceiptId, bestReasons)
+}
+
+// Function to score how similar two receipts are from 0 to 1, with the reasons that contributed to the score
+func similarity(receipt *model.Receipt, otherReceipt *model.Receipt) (float64, []string) {
+	var reasons []string
+
+	timeScore, minutesApart := timeSimilarity(receipt.PurchaseTime, otherReceipt.PurchaseTime)
+	if timeScore > 0 {
+		reasons = append(reasons, fmt.Sprintf("purchased %d minutes apart", minutesApart))
+	}
+	totalScore := totalSimilarity(receipt.TotalAmount, otherReceipt.TotalAmount)
+	if totalScore > 0 {
+		reasons = append(reasons, fmt.Sprintf("totals of %s and %s are %.0f%% similar", receipt.TotalAmount, otherReceipt.TotalAmount, totalScore*100))
+	}
+	itemsScore, sharedItems := itemsSimilarity(receipt.Items, otherReceipt.Items)
+	if sharedItems > 0 {
+		reasons = append(reasons, fmt.Sprintf("%d items in common", sharedItems))
+	}
+	return timeWeight*timeScore + totalWeight*totalScore + itemsWeight*itemsScore, reasons
+}
+
+// Function to score how close two purchase times are, times an hour or more apart score 0
+func timeSimilarity(purchaseTime string, otherPurchaseTime string) (float64, int) {
+	parsedTime, err := time.Parse("15:04", purchaseTime)
+	if err != nil {
+		return 0, 0
+	}
+	otherParsedTime, err := time.Parse("15:04", otherPurchaseTime)
+	if err != nil {
+		return 0, 0
+	}
+	apart := parsedTime.Sub(otherParsedTime).Abs()
+	return math.Max(0, 1-float64(apart)/float64(timeWindow)), int(apart.Minutes())
+}
+
+// Function to score how close two totals are relative to the larger total
+func totalSimilarity(total model.Money, otherTotal model.Money) float64 {
+	largest := math.Max(math.Abs(float64(total.Cents())), math.Abs(float64(otherTotal.Cents())))
+	if largest == 0 {
+		return 1
+	}
+	difference := math.Abs(float64(total.Sub(otherTotal).Cents()))
+	return math.Max(0, 1-difference/largest)
+}
+
+// Function to score how many items two receipts share, ignoring order, case and spacing, as the shared items over all distinct items
+func itemsSimilarity(items []model.ReceiptItem, otherItems []model.ReceiptItem) (float64, int) {
+	counts := make(map[string]int, len(items))
+	for _, item := range items {
+		counts[itemKey(item)]++
+	}
+	shared := 0
+	for _, item := range otherItems {
+		key := itemKey(item)
+		if counts[key] > 0 {
+			counts[key]--
+			shared++
+		}
+	}
+	all := len(items) + len(otherItems) - shared
+	if all == 0 {
+		return 0, 0
+	}
+	return float64(shared) / float64(all), shared
+}
+
+// Function to identify an item by its normalized description and price
+func itemKey(item model.ReceiptItem) string {
+	return strings.ToLower(strings.Join(strings.Fields(item.ShortDescription), " ")) + "|" + item.Price.String()
+}
@@ -1,13 +1,20 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
 	"net/http"
+	"receipt-processor-challenge/internal/receipt/currency"
 	"receipt-processor-challenge/internal/receipt/model"
+	"receipt-processor-challenge/internal/receipt/processor"
+	"receipt-processor-challenge/internal/receipt/repository"
 	"receipt-processor-challenge/internal/receipt/service"
 	"receipt-processor-challenge/internal/receipt/validator"
+	"receipt-processor-challenge/pkg/middleware"
 )
 
 type Handler struct {
@@ -33,19 +40,27 @@ func (receiptHandler *Handler) HandleReceiptProcessing(responseWriter http.Respo
 	var receipt model.Receipt
 	if err := json.NewDecoder(request.Body).Decode(&receipt); err != nil {
 		log.WithError(err).Error("failed to decode request body")
-		http.Error(responseWriter, "The receipt is invalid.", http.StatusBadRequest)
+		writeValidationProblem(responseWriter, log, []model.FieldError{decodeFieldError(err)})
 		return
 	}
 
-	if isValidReceipt := validator.IsValidReceipt(receipt); !isValidReceipt {
-		log.WithField("receipt", receipt).Error("invalid receipt")
-		http.Error(responseWriter, "The receipt is invalid.", http.StatusBadRequest)
+	profile := validator.ProfileForClient(clientIdFrom(ctx))
+	if fieldErrors := validator.ValidateReceiptWithProfile(receipt, profile); len(fieldErrors) > 0 {
+		log.WithFields(logrus.Fields{"receipt": receipt, "errors": fieldErrors, "validation_profile": profile.Name}).Error("invalid receipt")
+		writeValidationProblem(responseWriter, log, fieldErrors)
 		return
 	}
 
 	savedProcessedReceipt, err := receiptHandler.service.ProcessReceipt(ctx, &receipt)
+	var duplicateReceiptError *repository.DuplicateReceiptError
+	if errors.As(err, &duplicateReceiptError) {
+		log.WithField("original_receipt_id", duplicateReceiptError.OriginalID).Warn("duplicate receipt")
+		writeDuplicateReceiptProblem(responseWriter, log, duplicateReceiptError.OriginalID)
+		return
+	}
 	if err != nil {
-		http.Error(responseWriter, "The receipt is invalid.", http.StatusBadRequest)
+		log.WithError(err).Error("failed to process receipt")
+		writeProcessingProblem(responseWriter, log, err)
 		return
 	}
 	processedResponseId := savedProcessedReceipt.ID()
@@ -53,12 +68,48 @@ func (receiptHandler *Handler) HandleReceiptProcessing(responseWriter http.Respo
 
 	log.WithFields(logrus.Fields{"receipt_id": savedProcessedReceipt.ID()}).Info("receipt created successfully")
 	responseWriter.WriteHeader(http.StatusOK)
-	encodingFailed := json.NewEncoder(responseWriter).Encode(processedReceiptResponse)
-	if encodingFailed != nil {
+	err = json.NewEncoder(responseWriter).Encode(processedReceiptResponse)
+	if err != nil {
 		log.WithError(err).Error("failed to encode response body")
-		http.Error(responseWriter, "The receipt is invalid.", http.StatusBadRequest)
+	}
+}
+
+// Function for handling the scoring of receipts received through a http request without storing them
+func (receiptHandler *Handler) HandleReceiptSimulation(responseWriter http.ResponseWriter, request *http.Request) {
+	responseWriter.Header().Set("Content-Type", "application/json")
+
+	ctx := request.Context()
+	log := receiptHandler.logger.WithContext(ctx)
+
+	var receipt model.Receipt
+	if err := json.NewDecoder(request.Body).Decode(&receipt); err != nil {
+		log.WithError(err).Error("failed to decode request body")
+		writeValidationProblem(responseWriter, log, []model.FieldError{decodeFieldError(err)})
+		return
+	}
+
+	profile := validator.ProfileForClient(clientIdFrom(ctx))
+	if fieldErrors := validator.ValidateReceiptWithProfile(receipt, profile); len(fieldErrors) > 0 {
+		log.WithFields(logrus.Fields{"receipt": receipt, "errors": fieldErrors, "validation_profile": profile.Name}).Error("invalid receipt")
+		writeValidationProblem(responseWriter, log, fieldErrors)
+		return
+	}
+
+	simulatedReceipt, err := receiptHandler.service.SimulateReceipt(ctx, &receipt)
+	if err != nil {
+		log.WithError(err).Error("failed to simulate receipt")
+		writeProcessingProblem(responseWriter, log, err)
 		return
 	}
+
+	log.WithFields(logrus.Fields{"points": simulatedReceipt.Points()}).Info("receipt simulated successfully")
+	responseWriter.WriteHeader(http.StatusOK)
+
+	simulatedPointsResponse := model.NewSimulatedPointsResponse(simulatedReceipt.Points(), simulatedReceipt.RuleSetVersion(), simulatedReceipt.Breakdown())
+	err = json.NewEncoder(responseWriter).Encode(simulatedPointsResponse)
+	if err != nil {
+		log.WithError(err).Error("failed to encode response body")
+	}
 }
 
 // Function for handling the processing of receipts received through a http request
@@ -66,7 +117,7 @@ func (receiptHandler *Handler) HandleReceiptFetchById(responseWriter http.Respon
 	responseWriter.Header().Set("Content-Type", "application/json")
 
 	ctx := request.Context()
-	requestId := ctx.Value("request_id").(string)
+	requestId := ctx.Value(middleware.RequestIDKey).(string)
 	log := receiptHandler.logger.WithContext(ctx).WithFields(logrus.Fields{"request_id": requestId})
 	receiptId := mux.Vars(request)["id"]
 	if receiptId == "" {
@@ -92,3 +143,122 @@ func (receiptHandler *Handler) HandleReceiptFetchById(responseWriter http.Respon
 		log.WithError(err).Error("failed to encode receipt")
 	}
 }
+
+// Function for handling the fetching of a receipt's per rule points breakdown received through a http request
+func (receiptHandler *Handler) HandleReceiptBreakdownFetchById(responseWriter http.ResponseWriter, request *http.Request) {
+	responseWriter.Header().Set("Content-Type", "application/json")
+
+	ctx := request.Context()
+	requestId := ctx.Value(middleware.RequestIDKey).(string)
+	log := receiptHandler.logger.WithContext(ctx).WithFields(logrus.Fields{"request_id": requestId})
+	receiptId := mux.Vars(request)["id"]
+	if receiptId == "" {
+		log.Error("invalid receipt id")
+		http.Error(responseWriter, "The receipt is invalid.", http.StatusBadRequest)
+		return
+	}
+	receipt, err := receiptHandler.service.FindReceiptById(ctx, receiptId)
+	if err != nil {
+		log.WithError(err).Error("No receipt found for that ID:" + receiptId)
+		http.Error(responseWriter, "No receipt found for that ID.", http.StatusNotFound)
+		return
+	}
+
+	log.Info("receipt breakdown fetched successfully")
+	responseWriter.WriteHeader(http.StatusOK)
+
+	pointsBreakdownResponse := model.NewPointsBreakdownResponse(receipt.ID(), receipt.Points(), receipt.RuleSetVersion(), receipt.Breakdown(), receipt.Recalculations(), receipt.FraudReview())
+
+	err = json.NewEncoder(responseWriter).Encode(pointsBreakdownResponse)
+	if err != nil {
+		log.WithError(err).Error("failed to encode receipt breakdown")
+	}
+}
+
+// Function for handling the recalculation of every stored receipt under a rule set version received through a http request
+func (receiptHandler *Handler) HandleReceiptRecalculation(responseWriter http.ResponseWriter, request *http.Request) {
+	responseWriter.Header().Set("Content-Type", "application/json")
+
+	ctx := request.Context()
+	log := receiptHandler.logger.WithContext(ctx)
+
+	var recalculationRequest model.RecalculationRequest
+	if err := json.NewDecoder(request.Body).Decode(&recalculationRequest); err != nil || recalculationRequest.RuleSetVersion == "" {
+		log.WithError(err).Error("failed to decode request body")
+		http.Error(responseWriter, "The recalculation request is invalid.", http.StatusBadRequest)
+		return
+	}
+
+	recalculatedReceipts, err := receiptHandler.service.RecalculateReceipts(ctx, recalculationRequest.RuleSetVersion)
+	if errors.Is(err, processor.ErrRuleSetNotFound) {
+		log.WithError(err).Error("No rule set found for that version:" + recalculationRequest.RuleSetVersion)
+		http.Error(responseWriter, "No rule set found for that version.", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.WithError(err).Error("failed to recalculate receipts")
+		http.Error(responseWriter, "The receipts could not be recalculated.", http.StatusInternalServerError)
+		return
+	}
+
+	log.WithFields(logrus.Fields{"rule_set_version": recalculationRequest.RuleSetVersion, "receipt_count": len(recalculatedReceipts)}).Info("receipts recalculated successfully")
+	responseWriter.WriteHeader(http.StatusOK)
+
+	recalculationResponse := model.NewRecalculationResponse(recalculationRequest.RuleSetVersion, recalculatedReceipts)
+	err = json.NewEncoder(responseWriter).Encode(recalculationResponse)
+	if err != nil {
+		log.WithError(err).Error("failed to encode recalculation response")
+	}
+}
+
+// Function to write the field errors of an invalid receipt as a problem details body with a 400 status
+func writeValidationProblem(responseWriter http.ResponseWriter, log *logrus.Entry, fieldErrors []model.FieldError) {
+	responseWriter.Header().Set("Content-Type", "application/problem+json")
+	responseWriter.WriteHeader(http.StatusBadRequest)
+	err := json.NewEncoder(responseWriter).Encode(model.NewValidationProblemResponse(http.StatusBadRequest, fieldErrors))
+	if err != nil {
+		log.WithError(err).Error("failed to encode response body")
+	}
+}
+
+// Function to write a problem details body with a 409 status pointing at the receipt that was processed first
+func writeDuplicateReceiptProblem(responseWriter http.ResponseWriter, log *logrus.Entry, originalId string) {
+	responseWriter.Header().Set("Content-Type", "application/problem+json")
+	responseWriter.WriteHeader(http.StatusConflict)
+	err := json.NewEncoder(responseWriter).Encode(model.NewDuplicateReceiptResponse(http.StatusConflict, originalId))
+	if err != nil {
+		log.WithError(err).Error("failed to encode response body")
+	}
+}
+
+// Function to write a problem details body for a receipt that passed validation but couldn't be processed, a currency that lost its exchange rate
+// after the receipt was validated is reported as a field error with a 400 status and anything else, such as the store failing, with a 500 status
+func writeProcessingProblem(responseWriter http.ResponseWriter, log *logrus.Entry, err error) {
+	if errors.Is(err, processor.ErrUnsupportedCurrency) {
+		writeValidationProblem(responseWriter, log, []model.FieldError{
+			model.NewFieldError("$.currency", model.ValidationErrorUnknownValue, "currency has no exchange rate to "+currency.DefaultCurrency),
+		})
+		return
+	}
+	responseWriter.Header().Set("Content-Type", "application/problem+json")
+	responseWriter.WriteHeader(http.StatusInternalServerError)
+	encodeErr := json.NewEncoder(responseWriter).Encode(model.NewProcessingProblemResponse(http.StatusInternalServerError, "the receipt passed validation but could not be scored or stored, it can be sent again"))
+	if encodeErr != nil {
+		log.WithError(encodeErr).Error("failed to encode response body")
+	}
+}
+
+// Function to turn a request body decoding error into a field error, pointing at the field when the decoder knows it
+func decodeFieldError(err error) model.FieldError {
+	var typeError *json.UnmarshalTypeError
+	if errors.As(err, &typeError) && typeError.Field != "" {
+		return model.NewFieldError("$."+typeError.Field, model.ValidationErrorInvalidType, fmt.Sprintf("%s must be a %s", typeError.Field, typeError.Type))
+	}
+	return model.NewFieldError("$", model.ValidationErrorMalformedJSON, "the request body must be a json receipt")
+}
+
+// Function to get the id of the api client that sent the request, requests without a client id are validated with the default profile
+func clientIdFrom(ctx context.Context) string {
+	clientId, _ := ctx.Value(middleware.ClientID).(string)
+	return clientId
+}
@@ -0,0 +1,18 @@
+package model
+
+type FraudReview struct {
+	Score            float64  `json:"score"`
+	Flagged          bool     `json:"flagged"`
+	SimilarReceiptID string   `json:"similarReceiptId,omitempty"`
+	Reasons          []string `json:"reasons,omitempty"`
+}
+
+// Function to create a new FraudReview, the receipt is flagged for review when the score reaches the threshold
+func NewFraudReview(score float64, threshold float64, similarReceiptId string, reasons []string) FraudReview {
+	return FraudReview{
+		Score:            score,
+		Flagged:          score >= threshold,
+		SimilarReceiptID: similarReceiptId,
+		Reasons:          reasons,
+	}
+}
@@ -0,0 +1,149 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+)
+
+type Money struct {
+	cents int64
+	valid bool
+}
+
+var moneyRegex = regexp.MustCompile(`^(-?)(\d+)\.(\d{2})$`)
+
+// Function to create a new Money from a number of cents
+func NewMoney(cents int64) Money {
+	return Money{
+		cents: cents,
+		valid: true,
+	}
+}
+
+// Function to parse an amount formatted with exactly two decimal places such as 35.35, negative amounts such as -2.50 are used for returns and coupons
+func ParseMoney(value string) (Money, error) {
+	matches := moneyRegex.FindStringSubmatch(value)
+	if matches == nil {
+		return Money{}, fmt.Errorf("amount %q is not formatted like 0.00", value)
+	}
+	dollars, err := strconv.ParseInt(matches[2], 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("amount %q is out of range: %w", value, err)
+	}
+	cents, _ := strconv.ParseInt(matches[3], 10, 64)
+	if dollars > (math.MaxInt64-cents)/100 {
+		return Money{}, fmt.Errorf("amount %q is out of range", value)
+	}
+	amount := dollars*100 + cents
+	if matches[1] == "-" {
+		amount = -amount
+	}
+	return NewMoney(amount), nil
+}
+
+// Function to parse an amount, panicking if it is not formatted like 0.00 or -0.00. Intended for amounts known at compile time
+func MustParseMoney(value string) Money {
+	money, err := ParseMoney(value)
+	if err != nil {
+		panic(err)
+	}
+	return money
+}
+
+// the amount in cents
+func (m Money) Cents() int64 {
+	return m.cents
+}
+
+// whether the amount was successfully parsed or created, the zero value and unparsable json amounts are invalid
+func (m Money) IsValid() bool {
+	return m.valid
+}
+
+func (m Money) IsZero() bool {
+	return m.cents == 0
+}
+
+func (m Money) IsNegative() bool {
+	return m.cents < 0
+}
+
+// adds two amounts, the result is only valid if both amounts are
+func (m Money) Add(other Money) Money {
+	return Money{cents: m.cents + other.cents, valid: m.valid && other.valid}
+}
+
+// subtracts an amount, the result is only valid if both amounts are
+func (m Money) Sub(other Money) Money {
+	return Money{cents: m.cents - other.cents, valid: m.valid && other.valid}
+}
+
+// compares two amounts returning -1, 0 or 1
+func (m Money) Cmp(other Money) int {
+	switch {
+	case m.cents < other.cents:
+		return -1
+	case m.cents > other.cents:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// the absolute value of the amount
+func (m Money) Abs() Money {
+	if m.cents < 0 {
+		return Money{cents: -m.cents, valid: m.valid}
+	}
+	return m
+}
+
+// checks if the amount is an exact multiple of another amount
+func (m Money) IsMultipleOf(other Money) bool {
+	if other.cents == 0 {
+		return false
+	}
+	return m.cents%other.cents == 0
+}
+
+// checks if the amount is a round dollar value ending in .00
+func (m Money) IsRoundDollar() bool {
+	return m.IsMultipleOf(NewMoney(100))
+}
+
+// formats the amount with two decimal places such as 35.35
+func (m Money) String() string {
+	sign := ""
+	cents := m.cents
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	if !m.valid {
+		return json.Marshal("")
+	}
+	return json.Marshal(m.String())
+}
+
+// decodes a json string amount, amounts that aren't strings or can't be parsed are kept as invalid money so validation can report them
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		*m = Money{}
+		return nil
+	}
+	parsed, err := ParseMoney(value)
+	if err != nil {
+		*m = Money{}
+		return nil
+	}
+	*m = parsed
+	return nil
+}
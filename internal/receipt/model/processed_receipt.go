@@ -1,9 +1,35 @@
 package model
 
+import "encoding/json"
+
 type ProcessedReceipt struct {
-	receiptId string
-	receipt   *Receipt
-	points    int
+	receiptId      string
+	receipt        *Receipt
+	points         int
+	breakdown      []RuleBreakdown
+	ruleSetVersion string
+	recalculations []Recalculation
+	fingerprint    string
+	fraudReview    FraudReview
+}
+
+// the json form of a processed receipt, used to write it to disk since its own fields are unexported
+type processedReceiptJSON struct {
+	ID             string          `json:"id"`
+	Receipt        *Receipt        `json:"receipt"`
+	Points         int             `json:"points"`
+	Breakdown      []RuleBreakdown `json:"breakdown"`
+	RuleSetVersion string          `json:"ruleSetVersion"`
+	Recalculations []Recalculation `json:"recalculations,omitempty"`
+	Fingerprint    string          `json:"fingerprint,omitempty"`
+	FraudReview    FraudReview     `json:"fraudReview"`
+}
+
+type RuleBreakdown struct {
+	Rule      string `json:"rule"`
+	Points    int    `json:"points"`
+	Reason    string `json:"reason"`
+	Promotion string `json:"promotion,omitempty"`
 }
 
 type ProcessedReceiptResponse struct {
@@ -14,6 +40,29 @@ type PointsTotalResponse struct {
 	Points int
 }
 
+type PointsBreakdownResponse struct {
+	ID             string          `json:"id"`
+	Points         int             `json:"points"`
+	RuleSetVersion string          `json:"ruleSetVersion"`
+	Breakdown      []RuleBreakdown `json:"breakdown"`
+	Recalculations []Recalculation `json:"recalculations,omitempty"`
+	FraudReview    *FraudReview    `json:"fraudReview,omitempty"`
+}
+
+type DuplicateReceiptResponse struct {
+	Type       string `json:"type"`
+	Title      string `json:"title"`
+	Status     int    `json:"status"`
+	Detail     string `json:"detail"`
+	OriginalID string `json:"originalId"`
+}
+
+type SimulatedPointsResponse struct {
+	Points         int             `json:"points"`
+	RuleSetVersion string          `json:"ruleSetVersion"`
+	Breakdown      []RuleBreakdown `json:"breakdown"`
+}
+
 // Function to create a new PointsTotalResponse
 func NewPointsTotalResponse(points int) *PointsTotalResponse {
 	return &PointsTotalResponse{
@@ -21,12 +70,49 @@ func NewPointsTotalResponse(points int) *PointsTotalResponse {
 	}
 }
 
+// Function to create a new PointsBreakdownResponse
+func NewPointsBreakdownResponse(receiptId string, points int, ruleSetVersion string, breakdown []RuleBreakdown, recalculations []Recalculation, fraudReview FraudReview) *PointsBreakdownResponse {
+	response := &PointsBreakdownResponse{
+		ID:             receiptId,
+		Points:         points,
+		RuleSetVersion: ruleSetVersion,
+		Breakdown:      breakdown,
+		Recalculations: recalculations,
+	}
+	if fraudReview.Flagged {
+		response.FraudReview = &fraudReview
+	}
+	return response
+}
+
+// Function to create a new DuplicateReceiptResponse pointing at the receipt that was processed first
+func NewDuplicateReceiptResponse(status int, originalId string) *DuplicateReceiptResponse {
+	return &DuplicateReceiptResponse{
+		Type:       DuplicateReceiptProblemType,
+		Title:      "The receipt was already processed.",
+		Status:     status,
+		Detail:     "A receipt with the same retailer, purchase date, purchase time, total and items was already processed as " + originalId,
+		OriginalID: originalId,
+	}
+}
+
+// Function to create a new SimulatedPointsResponse
+func NewSimulatedPointsResponse(points int, ruleSetVersion string, breakdown []RuleBreakdown) *SimulatedPointsResponse {
+	return &SimulatedPointsResponse{
+		Points:         points,
+		RuleSetVersion: ruleSetVersion,
+		Breakdown:      breakdown,
+	}
+}
+
 // Function to create a new ProcessedReceipt
-func NewProcessedReceipt(receiptId string, receipt *Receipt, points int) *ProcessedReceipt {
+func NewProcessedReceipt(receiptId string, receipt *Receipt, points int, breakdown []RuleBreakdown, ruleSetVersion string) *ProcessedReceipt {
 	return &ProcessedReceipt{
-		receiptId: receiptId,
-		receipt:   receipt,
-		points:    points,
+		receiptId:      receiptId,
+		receipt:        receipt,
+		points:         points,
+		breakdown:      breakdown,
+		ruleSetVersion: ruleSetVersion,
 	}
 }
 
@@ -49,6 +135,89 @@ func (r ProcessedReceipt) Points() int {
 	return r.points
 }
 
+func (r ProcessedReceipt) Breakdown() []RuleBreakdown {
+	return r.breakdown
+}
+
+func (r ProcessedReceipt) RuleSetVersion() string {
+	return r.ruleSetVersion
+}
+
+func (r ProcessedReceipt) Recalculations() []Recalculation {
+	return r.recalculations
+}
+
+// gets the most recent recalculation of the receipt, if it was ever recalculated
+func (r ProcessedReceipt) LatestRecalculation() (Recalculation, bool) {
+	if len(r.recalculations) == 0 {
+		return Recalculation{}, false
+	}
+	return r.recalculations[len(r.recalculations)-1], true
+}
+
+// creates a copy of the processed receipt with the recalculation appended, the original points are left untouched
+func (r ProcessedReceipt) WithRecalculation(recalculation Recalculation) ProcessedReceipt {
+	recalculations := make([]Recalculation, len(r.recalculations), len(r.recalculations)+1)
+	copy(recalculations, r.recalculations)
+	r.recalculations = append(recalculations, recalculation)
+	return r
+}
+
+// gets the fingerprint identifying the receipt's contents, used to detect receipts that are submitted more than once
+func (r ProcessedReceipt) Fingerprint() string {
+	return r.fingerprint
+}
+
+// creates a copy of the processed receipt with a fingerprint of its contents
+func (r ProcessedReceipt) WithFingerprint(fingerprint string) ProcessedReceipt {
+	r.fingerprint = fingerprint
+	return r
+}
+
+// gets the result of comparing the receipt with similar receipts when it was processed
+func (r ProcessedReceipt) FraudReview() FraudReview {
+	return r.fraudReview
+}
+
+// creates a copy of the processed receipt with the result of its fraud review
+func (r ProcessedReceipt) WithFraudReview(fraudReview FraudReview) ProcessedReceipt {
+	r.fraudReview = fraudReview
+	return r
+}
+
+// encodes every field of the processed receipt so it can be read back with UnmarshalJSON
+func (r ProcessedReceipt) MarshalJSON() ([]byte, error) {
+	return json.Marshal(processedReceiptJSON{
+		ID:             r.receiptId,
+		Receipt:        r.receipt,
+		Points:         r.points,
+		Breakdown:      r.breakdown,
+		RuleSetVersion: r.ruleSetVersion,
+		Recalculations: r.recalculations,
+		Fingerprint:    r.fingerprint,
+		FraudReview:    r.fraudReview,
+	})
+}
+
+// decodes a processed receipt encoded with MarshalJSON
+func (r *ProcessedReceipt) UnmarshalJSON(data []byte) error {
+	var decoded processedReceiptJSON
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*r = ProcessedReceipt{
+		receiptId:      decoded.ID,
+		receipt:        decoded.Receipt,
+		points:         decoded.Points,
+		breakdown:      decoded.Breakdown,
+		ruleSetVersion: decoded.RuleSetVersion,
+		recalculations: decoded.Recalculations,
+		fingerprint:    decoded.Fingerprint,
+		fraudReview:    decoded.FraudReview,
+	}
+	return nil
+}
+
 func (r ProcessedReceipt) HasId() bool {
 	return r.receiptId != ""
 }
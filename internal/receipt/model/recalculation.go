@@ -0,0 +1,61 @@
+package model
+
+import "time"
+
+type Recalculation struct {
+	RuleSetVersion     string          `json:"ruleSetVersion"`
+	OriginalPoints     int             `json:"originalPoints"`
+	RecalculatedPoints int             `json:"recalculatedPoints"`
+	Delta              int             `json:"delta"`
+	Breakdown          []RuleBreakdown `json:"breakdown"`
+	RecalculatedAt     time.Time       `json:"recalculatedAt"`
+}
+
+type RecalculationRequest struct {
+	RuleSetVersion string `json:"ruleSetVersion"`
+}
+
+type ReceiptRecalculationResponse struct {
+	ID                 string `json:"id"`
+	OriginalPoints     int    `json:"originalPoints"`
+	RecalculatedPoints int    `json:"recalculatedPoints"`
+	Delta              int    `json:"delta"`
+}
+
+type RecalculationResponse struct {
+	RuleSetVersion string                         `json:"ruleSetVersion"`
+	Receipts       []ReceiptRecalculationResponse `json:"receipts"`
+}
+
+// Function to create a new Recalculation recording the original and recalculated points of a receipt
+func NewRecalculation(ruleSetVersion string, originalPoints int, recalculatedPoints int, breakdown []RuleBreakdown, recalculatedAt time.Time) Recalculation {
+	return Recalculation{
+		RuleSetVersion:     ruleSetVersion,
+		OriginalPoints:     originalPoints,
+		RecalculatedPoints: recalculatedPoints,
+		Delta:              recalculatedPoints - originalPoints,
+		Breakdown:          breakdown,
+		RecalculatedAt:     recalculatedAt,
+	}
+}
+
+// Function to create a new RecalculationResponse from the recalculated receipts
+func NewRecalculationResponse(ruleSetVersion string, receipts []ProcessedReceipt) *RecalculationResponse {
+	receiptResponses := make([]ReceiptRecalculationResponse, 0, len(receipts))
+	for _, receipt := range receipts {
+		recalculation, exists := receipt.LatestRecalculation()
+		if !exists {
+			continue
+		}
+		receiptResponses = append(receiptResponses, ReceiptRecalculationResponse{
+			ID:                 receipt.ID(),
+			OriginalPoints:     recalculation.OriginalPoints,
+			RecalculatedPoints: recalculation.RecalculatedPoints,
+			Delta:              recalculation.Delta,
+		})
+	}
+	return &RecalculationResponse{
+		RuleSetVersion: ruleSetVersion,
+		Receipts:       receiptResponses,
+	}
+}
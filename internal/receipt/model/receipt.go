@@ -4,11 +4,92 @@ type Receipt struct {
 	RetailerName string        `json:"retailer"`
 	PurchaseDate string        `json:"purchaseDate"`
 	PurchaseTime string        `json:"purchaseTime"`
-	TotalAmount  string        `json:"total"`
+	TimeZone     string        `json:"timeZone,omitempty"`
+	TotalAmount  Money         `json:"total"`
+	Currency     string        `json:"currency,omitempty"`
 	Items        []ReceiptItem `json:"items"`
+	Subtotal     *Money        `json:"subtotal,omitempty"`
+	Tax          *Money        `json:"tax,omitempty"`
+	Discounts    []Discount    `json:"discounts,omitempty"`
+	Tip          *Money        `json:"tip,omitempty"`
+}
+
+type Discount struct {
+	Description string `json:"description"`
+	Amount      Money  `json:"amount"`
 }
 
 type ReceiptItem struct {
 	ShortDescription string `json:"shortDescription"`
-	Price            string `json:"price"`
+	Price            Money  `json:"price"`
+	SKU              string `json:"sku,omitempty"`
+	UPC              string `json:"upc,omitempty"`
+	Quantity         int    `json:"quantity,omitempty"`
+	UnitPrice        *Money `json:"unitPrice,omitempty"`
+}
+
+// checks if the item line is a return or coupon, which are lines with a negative price
+func (item ReceiptItem) IsReturn() bool {
+	return item.Price.IsNegative()
+}
+
+// the number of units the item line is for, item lines without a quantity are for a single unit
+func (item ReceiptItem) Units() int {
+	if item.Quantity <= 0 {
+		return 1
+	}
+	return item.Quantity
+}
+
+// creates a deep copy of the receipt that shares no items or amounts with the original
+func (receipt Receipt) Clone() *Receipt {
+	clone := receipt
+	clone.Items = make([]ReceiptItem, len(receipt.Items))
+	for index, item := range receipt.Items {
+		item.UnitPrice = cloneMoney(item.UnitPrice)
+		clone.Items[index] = item
+	}
+	if receipt.Discounts != nil {
+		clone.Discounts = append([]Discount(nil), receipt.Discounts...)
+	}
+	clone.Subtotal = cloneMoney(receipt.Subtotal)
+	clone.Tax = cloneMoney(receipt.Tax)
+	clone.Tip = cloneMoney(receipt.Tip)
+	return &clone
+}
+
+// Function to copy an optional amount, amounts that are missing stay missing
+func cloneMoney(amount *Money) *Money {
+	if amount == nil {
+		return nil
+	}
+	clone := *amount
+	return &clone
+}
+
+// the sum of the item prices
+func (receipt Receipt) ItemsTotal() Money {
+	itemsTotal := NewMoney(0)
+	for _, item := range receipt.Items {
+		itemsTotal = itemsTotal.Add(item.Price)
+	}
+	return itemsTotal
+}
+
+// the total the receipt should have from its subtotal, or item prices when it has no subtotal, plus tax and tip minus discounts
+func (receipt Receipt) ExpectedTotal() Money {
+	expectedTotal := receipt.ItemsTotal()
+	if receipt.Subtotal != nil {
+		expectedTotal = *receipt.Subtotal
+	}
+	if receipt.Tax != nil {
+		expectedTotal = expectedTotal.Add(*receipt.Tax)
+	}
+	for _, discount := range receipt.Discounts {
+		expectedTotal = expectedTotal.Sub(discount.Amount)
+	}
+	if receipt.Tip != nil {
+		expectedTotal = expectedTotal.Add(*receipt.Tip)
+	}
+	return expectedTotal
 }
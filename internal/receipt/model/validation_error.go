@@ -0,0 +1,99 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	ValidationErrorRequired       = "required"
+	ValidationErrorInvalidFormat  = "invalid_format"
+	ValidationErrorInvalidType    = "invalid_type"
+	ValidationErrorNegativeAmount = "negative_amount"
+	ValidationErrorTotalMismatch  = "total_mismatch"
+	ValidationErrorUnknownValue   = "unknown_value"
+	ValidationErrorMalformedJSON  = "malformed_json"
+	ValidationErrorFutureDate     = "future_date"
+	ValidationErrorStaleReceipt   = "stale_receipt"
+)
+
+// RFC 9457 problem types of receipts that failed validation, of receipts that were already processed and of receipts that couldn't be processed
+const (
+	ValidationProblemType       = "urn:receipt-processor:problems:invalid-receipt"
+	DuplicateReceiptProblemType = "urn:receipt-processor:problems:duplicate-receipt"
+	ProcessingProblemType       = "urn:receipt-processor:problems:processing-failed"
+)
+
+type FieldError struct {
+	Path    string `json:"path"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type ValidationProblemResponse struct {
+	Type   string       `json:"type"`
+	Title  string       `json:"title"`
+	Status int          `json:"status"`
+	Detail string       `json:"detail"`
+	Errors []FieldError `json:"errors"`
+}
+
+type ProblemResponse struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail"`
+}
+
+// Function to create a new ProblemResponse for a receipt that passed validation but couldn't be processed or stored
+func NewProcessingProblemResponse(status int, detail string) *ProblemResponse {
+	return &ProblemResponse{
+		Type:   ProcessingProblemType,
+		Title:  "The receipt could not be processed.",
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// Function to create a new FieldError, the path is a JSON path into the receipt such as $.items[0].price
+func NewFieldError(path string, code string, message string) FieldError {
+	return FieldError{
+		Path:    path,
+		Code:    code,
+		Message: message,
+	}
+}
+
+// Function to create a new ValidationProblemResponse listing every field that failed validation
+func NewValidationProblemResponse(status int, fieldErrors []FieldError) *ValidationProblemResponse {
+	return &ValidationProblemResponse{
+		Type:   ValidationProblemType,
+		Title:  "The receipt is invalid.",
+		Status: status,
+		Detail: fmt.Sprintf("%d %s failed validation", len(fieldErrors), pluralize(len(fieldErrors), "field", "fields")),
+		Errors: fieldErrors,
+	}
+}
+
+// Function to build the JSON path of a field in the receipt from its json names and item indexes
+func FieldPath(segments ...interface{}) string {
+	var path strings.Builder
+	path.WriteString("$")
+	for _, segment := range segments {
+		switch value := segment.(type) {
+		case int:
+			fmt.Fprintf(&path, "[%d]", value)
+		default:
+			fmt.Fprintf(&path, ".%v", value)
+		}
+	}
+	return path.String()
+}
+
+// Function to pick the singular or plural form of a word for a count
+func pluralize(count int, singular string, plural string) string {
+	if count == 1 {
+		return singular
+	}
+	return plural
+}
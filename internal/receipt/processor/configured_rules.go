@@ -0,0 +1,301 @@
+package processor
+
+import (
+	"fmt"
+	"github.com/spf13/cast"
+	"math"
+	"receipt-processor-challenge/internal/receipt/model"
+	"receipt-processor-challenge/pkg/config"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ruleFactory func(name string, description string, params map[string]interface{}) (Rule, error)
+
+var ruleFactories = map[string]ruleFactory{
+	"retailer-alphanumeric": newRetailerAlphanumericRule,
+	"round-dollar":          newRoundDollarRule,
+	"quarter-multiple":      newQuarterMultipleRule,
+	"item-pairs":            newItemPairsRule,
+	"description-length":    newDescriptionLengthRule,
+	"odd-day":               newOddDayRule,
+	"purchase-time-window":  newPurchaseTimeWindowRule,
+	"flat-bonus":            newFlatBonusRule,
+	"product-bonus":         newProductBonusRule,
+}
+
+// Function to build the rules, retailer programs and points limits declared in a rule set config
+func BuildRuleSet(ruleSetConfig *config.RuleSetConfig) (RuleSet, error) {
+	rules, err := BuildRules(ruleSetConfig)
+	if err != nil {
+		return RuleSet{}, err
+	}
+	retailerPrograms, err := buildRetailerPrograms(ruleSetConfig.RetailerPrograms)
+	if err != nil {
+		return RuleSet{}, err
+	}
+	limits, err := buildPointsLimits(ruleSetConfig.Limits, rules)
+	if err != nil {
+		return RuleSet{}, err
+	}
+	return RuleSet{
+		Version:          ruleSetConfig.Version,
+		Checksum:         ruleSetConfig.Checksum,
+		Rules:            rules,
+		RetailerPrograms: retailerPrograms,
+		Limits:           limits,
+	}, nil
+}
+
+// Function to build the rules declared in a rule set config, keeping the order they were declared in
+func BuildRules(ruleSetConfig *config.RuleSetConfig) ([]Rule, error) {
+	rules := make([]Rule, 0, len(ruleSetConfig.Rules))
+	for _, ruleConfig := range ruleSetConfig.Rules {
+		factory, exists := ruleFactories[ruleConfig.Type]
+		if !exists {
+			return nil, fmt.Errorf("rule type %s is not supported", ruleConfig.Type)
+		}
+		name := ruleConfig.Name
+		if name == "" {
+			name = ruleConfig.Type
+		}
+		rule, err := factory(name, ruleConfig.Description, ruleConfig.Params)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build rule %s: %w", name, err)
+		}
+		if ruleConfig.Promotion != nil {
+			promotion, err := newPromotion(ruleConfig.Promotion)
+			if err != nil {
+				return nil, fmt.Errorf("failed to build rule %s: %w", name, err)
+			}
+			rule = NewPromotionalRule(rule, promotion)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// Function to create a rule awarding points for every alphanumeric character in the retailer name
+func newRetailerAlphanumericRule(name string, description string, params map[string]interface{}) (Rule, error) {
+	pointsPerCharacter, err := intParam(params, "points_per_character", 1)
+	if err != nil {
+		return nil, err
+	}
+	alphanumericRegex := regexp.MustCompile(`[a-zA-Z0-9]`)
+	return NewRule(name, descriptionOrDefault(description, fmt.Sprintf("%d points for every alphanumeric character in the retailer name.", pointsPerCharacter)), func(receipt *model.Receipt) RuleResult {
+		characters := len(alphanumericRegex.FindAllString(receipt.RetailerName, -1))
+		return RuleResult{Points: characters * pointsPerCharacter, Reason: fmt.Sprintf("%d alphanumeric characters in the retailer name", characters)}
+	}), nil
+}
+
+// Function to create a rule awarding points when the total is a round dollar amount
+func newRoundDollarRule(name string, description string, params map[string]interface{}) (Rule, error) {
+	points, err := intParam(params, "points", 50)
+	if err != nil {
+		return nil, err
+	}
+	return NewRule(name, descriptionOrDefault(description, fmt.Sprintf("%d points if the total is a round dollar amount with no cents.", points)), func(receipt *model.Receipt) RuleResult {
+		if isRoundDollar(receipt.TotalAmount) {
+			return RuleResult{Points: points, Reason: "total is a round dollar amount"}
+		}
+		return RuleResult{Points: 0, Reason: "total is not a round dollar amount"}
+	}), nil
+}
+
+// Function to create a rule awarding points when the total is a multiple of a configurable amount
+func newQuarterMultipleRule(name string, description string, params map[string]interface{}) (Rule, error) {
+	points, err := intParam(params, "points", 25)
+	if err != nil {
+		return nil, err
+	}
+	multiple, err := floatParam(params, "multiple", 0.25)
+	if err != nil {
+		return nil, err
+	}
+	multipleInCents := int64(math.Round(multiple * 100))
+	if multipleInCents <= 0 {
+		return nil, fmt.Errorf("multiple must be at least 0.01 but got %v", multiple)
+	}
+	multipleStr := strconv.FormatFloat(multiple, 'f', 2, 64)
+	return NewRule(name, descriptionOrDefault(description, fmt.Sprintf("%d points if the total is a multiple of %s.", points, multipleStr)), func(receipt *model.Receipt) RuleResult {
+		if isMultipleOfCents(receipt.TotalAmount, multipleInCents) {
+			return RuleResult{Points: points, Reason: "total is a multiple of " + multipleStr}
+		}
+		return RuleResult{Points: 0, Reason: "total is not a multiple of " + multipleStr}
+	}), nil
+}
+
+// Function to create a rule awarding points for every group of items on the receipt, returned items cancel out purchased items
+func newItemPairsRule(name string, description string, params map[string]interface{}) (Rule, error) {
+	pointsPerGroup, err := intParam(params, "points_per_group", 5)
+	if err != nil {
+		return nil, err
+	}
+	groupSize, err := intParam(params, "group_size", 2)
+	if err != nil {
+		return nil, err
+	}
+	if groupSize <= 0 {
+		return nil, fmt.Errorf("group_size must be positive but got %d", groupSize)
+	}
+	return NewRule(name, descriptionOrDefault(description, fmt.Sprintf("%d points for every %d items on the receipt.", pointsPerGroup, groupSize)), func(receipt *model.Receipt) RuleResult {
+		groups := netItemCount(receipt.Items) / groupSize
+		return RuleResult{Points: groups * pointsPerGroup, Reason: fmt.Sprintf("%d groups of %d items on the receipt", groups, groupSize)}
+	}), nil
+}
+
+// Function to create a rule awarding a share of the item price when the trimmed description length is a multiple of a configurable length,
+// returned items take away the points they would have earned and the total never goes below zero
+func newDescriptionLengthRule(name string, description string, params map[string]interface{}) (Rule, error) {
+	lengthMultiple, err := intParam(params, "length_multiple", 3)
+	if err != nil {
+		return nil, err
+	}
+	if lengthMultiple <= 0 {
+		return nil, fmt.Errorf("length_multiple must be positive but got %d", lengthMultiple)
+	}
+	priceMultiplier, err := floatParam(params, "price_multiplier", 0.2)
+	if err != nil {
+		return nil, err
+	}
+	priceMultiplierTenThousandths := int64(math.Round(priceMultiplier * 10000))
+	return NewRule(name, descriptionOrDefault(description, fmt.Sprintf("If the trimmed length of the item description is a multiple of %d, multiply the price by %v and round up to the nearest integer.", lengthMultiple, priceMultiplier)), func(receipt *model.Receipt) RuleResult {
+		pointTotal := 0
+		matchingItems := 0
+		for _, item := range receipt.Items {
+			trimmedDescription := strings.TrimSpace(item.ShortDescription)
+			if len(trimmedDescription)%lengthMultiple != 0 {
+				continue
+			}
+			if !item.Price.IsValid() {
+				continue
+			}
+			matchingItems++
+			pointTotal += multiplySignedPriceRoundingUp(item.Price, priceMultiplierTenThousandths)
+		}
+		pointTotal = max(pointTotal, 0)
+		return RuleResult{Points: pointTotal, Reason: fmt.Sprintf("%d item descriptions with a length that is a multiple of %d", matchingItems, lengthMultiple)}
+	}), nil
+}
+
+// Function to create a rule awarding points when the day in the purchase date is odd
+func newOddDayRule(name string, description string, params map[string]interface{}) (Rule, error) {
+	points, err := intParam(params, "points", 6)
+	if err != nil {
+		return nil, err
+	}
+	return NewRule(name, descriptionOrDefault(description, fmt.Sprintf("%d points if the day in the local purchase date is odd.", points)), func(receipt *model.Receipt) RuleResult {
+		purchasedAt, err := localPurchaseTime(receipt)
+		if err != nil {
+			return RuleResult{Points: 0, Reason: "purchase date can't be read"}
+		}
+		if purchasedAt.Day()%2 != 0 {
+			return RuleResult{Points: points, Reason: "purchase day is odd"}
+		}
+		return RuleResult{Points: 0, Reason: "purchase day is not odd"}
+	}), nil
+}
+
+// Function to create a rule awarding points when the purchase time falls in a configurable window, the start is inclusive and the end exclusive
+func newPurchaseTimeWindowRule(name string, description string, params map[string]interface{}) (Rule, error) {
+	points, err := intParam(params, "points", 10)
+	if err != nil {
+		return nil, err
+	}
+	start, err := clockParam(params, "start", "14:00")
+	if err != nil {
+		return nil, err
+	}
+	end, err := clockParam(params, "end", "16:00")
+	if err != nil {
+		return nil, err
+	}
+	if !start.Before(end) {
+		return nil, fmt.Errorf("start %s must be before end %s", start.Format("15:04"), end.Format("15:04"))
+	}
+	window := start.Format("15:04") + " and " + end.Format("15:04")
+	return NewRule(name, descriptionOrDefault(description, fmt.Sprintf("%d points if the local time of purchase is between %s.", points, window)), func(receipt *model.Receipt) RuleResult {
+		purchasedAt, err := localPurchaseTime(receipt)
+		if err != nil {
+			return RuleResult{Points: 0, Reason: "purchase time can't be read"}
+		}
+		// only the clock time is compared, so the local time is moved onto the same zero date the window was parsed on
+		purchaseTime := time.Date(0, time.January, 1, purchasedAt.Hour(), purchasedAt.Minute(), 0, 0, time.UTC)
+		if !purchaseTime.Before(start) && purchaseTime.Before(end) {
+			return RuleResult{Points: points, Reason: "purchase time is between " + window}
+		}
+		return RuleResult{Points: 0, Reason: "purchase time is not between " + window}
+	}), nil
+}
+
+// Function to create a rule awarding a flat number of points on every receipt, usually limited to a promotion
+func newFlatBonusRule(name string, description string, params map[string]interface{}) (Rule, error) {
+	points, err := intParam(params, "points", 0)
+	if err != nil {
+		return nil, err
+	}
+	return NewRule(name, descriptionOrDefault(description, fmt.Sprintf("%d bonus points.", points)), func(receipt *model.Receipt) RuleResult {
+		return RuleResult{Points: points, Reason: fmt.Sprintf("%d bonus points", points)}
+	}), nil
+}
+
+// Function to read an integer parameter, falling back to the default when it is missing
+func intParam(params map[string]interface{}, key string, defaultValue int) (int, error) {
+	value, exists := params[key]
+	if !exists {
+		return defaultValue, nil
+	}
+	converted, err := cast.ToIntE(value)
+	if err != nil {
+		return 0, fmt.Errorf("parameter %s must be an integer: %w", key, err)
+	}
+	return converted, nil
+}
+
+// Function to read a decimal parameter, falling back to the default when it is missing
+func floatParam(params map[string]interface{}, key string, defaultValue float64) (float64, error) {
+	value, exists := params[key]
+	if !exists {
+		return defaultValue, nil
+	}
+	converted, err := cast.ToFloat64E(value)
+	if err != nil {
+		return 0, fmt.Errorf("parameter %s must be a number: %w", key, err)
+	}
+	return converted, nil
+}
+
+// Function to read a parameter holding a list of strings, a single string is treated as a list of one
+func stringListParam(params map[string]interface{}, key string) []string {
+	value, exists := params[key]
+	if !exists {
+		return nil
+	}
+	if _, isString := value.(string); isString {
+		return []string{cast.ToString(value)}
+	}
+	return cast.ToStringSlice(value)
+}
+
+// Function to read a 24-hour clock parameter such as 14:00, falling back to the default when it is missing
+func clockParam(params map[string]interface{}, key string, defaultValue string) (time.Time, error) {
+	value := defaultValue
+	if rawValue, exists := params[key]; exists {
+		value = cast.ToString(rawValue)
+	}
+	parsedTime, err := time.Parse("15:04", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parameter %s must be a time in the format HH:MM: %w", key, err)
+	}
+	return parsedTime, nil
+}
+
+// Function to use the configured description when one was given
+func descriptionOrDefault(description string, defaultDescription string) string {
+	if description != "" {
+		return description
+	}
+	return defaultDescription
+}
@@ -0,0 +1,62 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"receipt-processor-challenge/internal/receipt/currency"
+	"receipt-processor-challenge/internal/receipt/model"
+)
+
+var ErrUnsupportedCurrency = errors.New("currency has no exchange rate")
+
+// Function to set the exchange rate provider used to normalize receipts to the default currency before the rules are evaluated,
+// receipts are validated against the currencies it has rates for
+func SetExchangeRateProvider(provider currency.ExchangeRateProvider) {
+	currency.SetDefaultProvider(provider)
+}
+
+// Function to create a copy of a receipt with every amount converted to the default currency, so dollar based rules see dollars
+func normalizeReceipt(receipt *model.Receipt) (*model.Receipt, error) {
+	code := currency.CodeOrDefault(receipt.Currency)
+	if code == currency.DefaultCurrency {
+		return receipt, nil
+	}
+
+	rate, err := currency.DefaultProvider().Rate(code, currency.DefaultCurrency)
+	if err != nil {
+		return nil, fmt.Errorf("failed to normalize receipt from %s to %s: %w: %w", code, currency.DefaultCurrency, ErrUnsupportedCurrency, err)
+	}
+
+	normalizedReceipt := *receipt
+	normalizedReceipt.Currency = currency.DefaultCurrency
+	normalizedReceipt.TotalAmount = currency.Convert(receipt.TotalAmount, rate)
+	normalizedReceipt.Items = make([]model.ReceiptItem, len(receipt.Items))
+	for index, item := range receipt.Items {
+		item.Price = currency.Convert(item.Price, rate)
+		if item.UnitPrice != nil {
+			unitPrice := currency.Convert(*item.UnitPrice, rate)
+			item.UnitPrice = &unitPrice
+		}
+		normalizedReceipt.Items[index] = item
+	}
+	normalizedReceipt.Subtotal = convertOptional(receipt.Subtotal, rate)
+	normalizedReceipt.Tax = convertOptional(receipt.Tax, rate)
+	normalizedReceipt.Tip = convertOptional(receipt.Tip, rate)
+	if receipt.Discounts != nil {
+		normalizedReceipt.Discounts = make([]model.Discount, len(receipt.Discounts))
+		for index, discount := range receipt.Discounts {
+			discount.Amount = currency.Convert(discount.Amount, rate)
+			normalizedReceipt.Discounts[index] = discount
+		}
+	}
+	return &normalizedReceipt, nil
+}
+
+// Function to convert an optional amount, amounts that are missing stay missing
+func convertOptional(amount *model.Money, rate currency.Rate) *model.Money {
+	if amount == nil {
+		return nil
+	}
+	converted := currency.Convert(*amount, rate)
+	return &converted
+}
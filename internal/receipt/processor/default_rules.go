@@ -0,0 +1,56 @@
+package processor
+
+import (
+	"fmt"
+	"receipt-processor-challenge/internal/receipt/model"
+)
+
+// Function to create the default rule set according to the business rules
+func DefaultRules() []Rule {
+	return []Rule{
+		NewRule("retailer-name", "One point for every alphanumeric character in the retailer name.", func(receipt *model.Receipt) RuleResult {
+			points := calculatePointFromRetailerName(receipt.RetailerName)
+			return RuleResult{Points: points, Reason: fmt.Sprintf("%d alphanumeric characters in the retailer name", points)}
+		}),
+		NewRule("round-dollar-total", "50 points if the total is a round dollar amount with no cents.", func(receipt *model.Receipt) RuleResult {
+			points := calculatePointsFromRoundTotalAmount(receipt.TotalAmount)
+			return RuleResult{Points: points, Reason: reasonFor(points, "total is a round dollar amount", "total is not a round dollar amount")}
+		}),
+		NewRule("quarter-multiple-total", "25 points if the total is a multiple of 0.25.", func(receipt *model.Receipt) RuleResult {
+			points := calculatePointsFromMultipleOfQuarterAmount(receipt.TotalAmount)
+			return RuleResult{Points: points, Reason: reasonFor(points, "total is a multiple of 0.25", "total is not a multiple of 0.25")}
+		}),
+		NewRule("item-pairs", "5 points for every two items on the receipt.", func(receipt *model.Receipt) RuleResult {
+			points := calculatePointsFromNumberItemsOnReceipt(receipt.Items)
+			return RuleResult{Points: points, Reason: fmt.Sprintf("%d pairs of items on the receipt", netItemCount(receipt.Items)/2)}
+		}),
+		NewRule("item-description-length", "If the trimmed length of the item description is a multiple of 3, multiply the price by 0.2 and round up to the nearest integer.", func(receipt *model.Receipt) RuleResult {
+			points := calculatePointsForItemDescriptionLengthIsMultipleOfThree(receipt.Items)
+			return RuleResult{Points: points, Reason: reasonFor(points, "item descriptions with a length that is a multiple of 3", "no item descriptions with a length that is a multiple of 3")}
+		}),
+		NewRule("odd-purchase-day", "6 points if the day in the local purchase date is odd.", func(receipt *model.Receipt) RuleResult {
+			purchasedAt, err := localPurchaseTime(receipt)
+			if err != nil {
+				return RuleResult{Points: 0, Reason: "purchase date can't be read"}
+			}
+			points := calculatePointsFromPurchaseDayBeingOdd(purchasedAt)
+			return RuleResult{Points: points, Reason: reasonFor(points, "purchase day is odd", "purchase day is not odd")}
+		}),
+		NewRule("afternoon-purchase-time", "10 points if the local time of purchase is after 2:00pm and before 4:00pm.", func(receipt *model.Receipt) RuleResult {
+			purchasedAt, err := localPurchaseTime(receipt)
+			if err != nil {
+				return RuleResult{Points: 0, Reason: "purchase time can't be read"}
+			}
+			points := calculatePointsFromPurchaseTimeBeingBetweenTwoAndFourPM(purchasedAt)
+			return RuleResult{Points: points, Reason: reasonFor(points, "purchase time is between 2:00pm and 4:00pm", "purchase time is not between 2:00pm and 4:00pm")}
+		}),
+	}
+}
+
+// Function to pick the reason that matches whether a rule awarded any points
+func reasonFor(points int, awarded string, notAwarded string) string {
+	if points > 0 {
+		return awarded
+	}
+	return notAwarded
+}
@@ -0,0 +1,129 @@
+package processor
+
+import (
+	"fmt"
+	"receipt-processor-challenge/internal/receipt/model"
+	"receipt-processor-challenge/pkg/config"
+	"strings"
+)
+
+// a cap and/or floor on points. A limit naming rules applies to the points those rules contribute before retailer programs,
+// a limit without rules applies to the whole receipt after retailer programs. Limits only see the receipt being scored, not the other receipts of its user
+type PointsLimit struct {
+	Name  string
+	Rules []string
+	Min   *int
+	Max   *int
+}
+
+// Function to create a new points limit, min and max are optional but at least one is required
+func NewPointsLimit(name string, rules []string, min *int, max *int) (PointsLimit, error) {
+	if name == "" {
+		return PointsLimit{}, fmt.Errorf("points limit name is required")
+	}
+	if min == nil && max == nil {
+		return PointsLimit{}, fmt.Errorf("points limit %s needs a min or a max", name)
+	}
+	if min != nil && max != nil && *min > *max {
+		return PointsLimit{}, fmt.Errorf("points limit %s has a min of %d above its max of %d", name, *min, *max)
+	}
+	return PointsLimit{
+		Name:  name,
+		Rules: rules,
+		Min:   min,
+		Max:   max,
+	}, nil
+}
+
+// Function to build the points limits declared in a rule set config, every rule a limit names has to be one of the rules of the rule set
+func buildPointsLimits(limitConfigs []config.PointsLimitConfig, rules []Rule) ([]PointsLimit, error) {
+	ruleNames := make(map[string]struct{}, len(rules))
+	for _, rule := range rules {
+		ruleNames[rule.Name()] = struct{}{}
+	}
+
+	limits := make([]PointsLimit, 0, len(limitConfigs))
+	for _, limitConfig := range limitConfigs {
+		limit, err := NewPointsLimit(limitConfig.Name, limitConfig.Rules, limitConfig.Min, limitConfig.Max)
+		if err != nil {
+			return nil, err
+		}
+		for _, ruleName := range limit.Rules {
+			if _, exists := ruleNames[ruleName]; !exists {
+				return nil, fmt.Errorf("points limit %s names rule %s, which is not in the rule set", limit.Name, ruleName)
+			}
+		}
+		limits = append(limits, limit)
+	}
+	return limits, nil
+}
+
+// whether the limit applies to the whole receipt rather than a category of rules
+func (limit PointsLimit) IsReceiptLimit() bool {
+	return len(limit.Rules) == 0
+}
+
+// Function to apply the limits on categories of rules, each trims or raises the points those rules contributed
+func applyCategoryLimits(limits []PointsLimit, ruleBreakdown []model.RuleBreakdown, points int) (int, []model.RuleBreakdown) {
+	var limitBreakdown []model.RuleBreakdown
+	for _, limit := range limits {
+		if limit.IsReceiptLimit() {
+			continue
+		}
+		categoryPoints := 0
+		for _, contribution := range ruleBreakdown {
+			if limit.covers(contribution.Rule) {
+				categoryPoints += contribution.Points
+			}
+		}
+		if adjustment, reason, limited := limit.adjust(categoryPoints); limited {
+			points += adjustment
+			limitBreakdown = append(limitBreakdown, model.RuleBreakdown{
+				Rule:   limit.Name,
+				Points: adjustment,
+				Reason: fmt.Sprintf("points from %s %s", strings.Join(limit.Rules, ", "), reason),
+			})
+		}
+	}
+	return points, limitBreakdown
+}
+
+// Function to apply the limits on the whole receipt in order, each trims or raises the total points
+func applyReceiptLimits(limits []PointsLimit, points int) (int, []model.RuleBreakdown) {
+	var limitBreakdown []model.RuleBreakdown
+	for _, limit := range limits {
+		if !limit.IsReceiptLimit() {
+			continue
+		}
+		if adjustment, reason, limited := limit.adjust(points); limited {
+			points += adjustment
+			limitBreakdown = append(limitBreakdown, model.RuleBreakdown{
+				Rule:   limit.Name,
+				Points: adjustment,
+				Reason: "receipt points " + reason,
+			})
+		}
+	}
+	return points, limitBreakdown
+}
+
+// works out how many points to add or remove to bring points within the limit
+func (limit PointsLimit) adjust(points int) (int, string, bool) {
+	if limit.Max != nil && points > *limit.Max {
+		return *limit.Max - points, fmt.Sprintf("capped at %d", *limit.Max), true
+	}
+	if limit.Min != nil && points < *limit.Min {
+		return *limit.Min - points, fmt.Sprintf("raised to the minimum of %d", *limit.Min), true
+	}
+	return 0, "", false
+}
+
+// checks if a rule belongs to the limit's category
+func (limit PointsLimit) covers(ruleName string) bool {
+	for _, name := range limit.Rules {
+		if name == ruleName {
+			return true
+		}
+	}
+	return false
+}
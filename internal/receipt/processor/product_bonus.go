@@ -0,0 +1,79 @@
+package processor
+
+import (
+	"fmt"
+	"receipt-processor-challenge/internal/receipt/model"
+	"regexp"
+	"strings"
+)
+
+// identifies the items a product bonus applies to, an item matches when any of the given identifiers match
+type ProductMatch struct {
+	SKUs               []string
+	UPCs               []string
+	DescriptionPattern *regexp.Regexp
+}
+
+// Function to create a rule awarding points for every unit of a matching item on the receipt, such as 500 points per Gatorade. Returned units are taken away
+func NewProductBonusRule(name string, description string, match ProductMatch, pointsPerUnit int) Rule {
+	return NewRule(name, description, func(receipt *model.Receipt) RuleResult {
+		matchingUnits := 0
+		for _, item := range receipt.Items {
+			if !match.Matches(item) {
+				continue
+			}
+			if item.IsReturn() {
+				matchingUnits -= item.Units()
+			} else {
+				matchingUnits += item.Units()
+			}
+		}
+		matchingUnits = max(matchingUnits, 0)
+		return RuleResult{Points: matchingUnits * pointsPerUnit, Reason: fmt.Sprintf("%d matching product units", matchingUnits)}
+	})
+}
+
+// Function to create a product bonus rule from its params, items are matched by sku, upc or a description pattern
+func newProductBonusRule(name string, description string, params map[string]interface{}) (Rule, error) {
+	pointsPerUnit, err := intParam(params, "points", 0)
+	if err != nil {
+		return nil, err
+	}
+	match := ProductMatch{
+		SKUs: stringListParam(params, "skus"),
+		UPCs: stringListParam(params, "upcs"),
+	}
+	if rawPattern, exists := params["description_pattern"]; exists {
+		pattern, err := regexp.Compile(fmt.Sprint(rawPattern))
+		if err != nil {
+			return nil, fmt.Errorf("parameter description_pattern must be a valid pattern: %w", err)
+		}
+		match.DescriptionPattern = pattern
+	}
+	if len(match.SKUs) == 0 && len(match.UPCs) == 0 && match.DescriptionPattern == nil {
+		return nil, fmt.Errorf("at least one of skus, upcs or description_pattern is required")
+	}
+	return NewProductBonusRule(name, descriptionOrDefault(description, fmt.Sprintf("%d points for every unit of a matching product.", pointsPerUnit)), match, pointsPerUnit), nil
+}
+
+// checks if an item is one of the products, skus are compared case-insensitively
+func (match ProductMatch) Matches(item model.ReceiptItem) bool {
+	if item.SKU != "" {
+		for _, sku := range match.SKUs {
+			if strings.EqualFold(item.SKU, sku) {
+				return true
+			}
+		}
+	}
+	if item.UPC != "" {
+		for _, upc := range match.UPCs {
+			if item.UPC == upc {
+				return true
+			}
+		}
+	}
+	if match.DescriptionPattern != nil {
+		return match.DescriptionPattern.MatchString(strings.TrimSpace(item.ShortDescription))
+	}
+	return false
+}
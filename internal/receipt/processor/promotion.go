@@ -0,0 +1,127 @@
+package processor
+
+import (
+	"fmt"
+	"receipt-processor-challenge/internal/receipt/model"
+	"receipt-processor-challenge/internal/receipt/timezone"
+	"receipt-processor-challenge/pkg/config"
+	"strings"
+	"time"
+)
+
+type Promotion struct {
+	Name  string
+	Start time.Time
+	End   time.Time
+	Days  []time.Weekday
+}
+
+type promotionalRule struct {
+	rule      Rule
+	promotion Promotion
+}
+
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+// Function to wrap a rule so it only contributes points for purchases made while the promotion is active
+func NewPromotionalRule(rule Rule, promotion Promotion) Rule {
+	return &promotionalRule{
+		rule:      rule,
+		promotion: promotion,
+	}
+}
+
+// Function to create a promotion from its config, a date only end includes the whole end day while a date and time end is exclusive
+func newPromotion(promotionConfig *config.PromotionConfig) (Promotion, error) {
+	promotion := Promotion{Name: promotionConfig.Name}
+	if promotion.Name == "" {
+		return Promotion{}, fmt.Errorf("promotion name is required")
+	}
+
+	if promotionConfig.Start != "" {
+		start, _, err := parsePromotionTime(promotionConfig.Start)
+		if err != nil {
+			return Promotion{}, fmt.Errorf("promotion %s has an invalid start: %w", promotion.Name, err)
+		}
+		promotion.Start = start
+	}
+	if promotionConfig.End != "" {
+		end, dateOnly, err := parsePromotionTime(promotionConfig.End)
+		if err != nil {
+			return Promotion{}, fmt.Errorf("promotion %s has an invalid end: %w", promotion.Name, err)
+		}
+		if dateOnly {
+			end = end.AddDate(0, 0, 1)
+		}
+		promotion.End = end
+	}
+	if !promotion.Start.IsZero() && !promotion.End.IsZero() && !promotion.Start.Before(promotion.End) {
+		return Promotion{}, fmt.Errorf("promotion %s must start before it ends", promotion.Name)
+	}
+
+	for _, day := range promotionConfig.Days {
+		weekday, exists := weekdays[strings.ToLower(day)]
+		if !exists {
+			return Promotion{}, fmt.Errorf("promotion %s has an unknown day %s", promotion.Name, day)
+		}
+		promotion.Days = append(promotion.Days, weekday)
+	}
+	return promotion, nil
+}
+
+// Function to parse a promotion start or end given as a date or a date and time, reporting whether only a date was given
+func parsePromotionTime(value string) (time.Time, bool, error) {
+	if parsedTime, err := time.Parse("2006-01-02 15:04", value); err == nil {
+		return parsedTime, false, nil
+	}
+	parsedDate, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("%q must be formatted like 2006-01-02 or 2006-01-02 15:04", value)
+	}
+	return parsedDate, true, nil
+}
+
+// checks if the promotion is active at a purchase time, the start is inclusive and the end exclusive. Promotion times have no zone and are compared with the local wall clock
+func (promotion Promotion) IsActive(purchasedAt time.Time) bool {
+	if !promotion.Start.IsZero() && purchasedAt.Before(promotion.Start) {
+		return false
+	}
+	if !promotion.End.IsZero() && !purchasedAt.Before(promotion.End) {
+		return false
+	}
+	if len(promotion.Days) == 0 {
+		return true
+	}
+	for _, day := range promotion.Days {
+		if purchasedAt.Weekday() == day {
+			return true
+		}
+	}
+	return false
+}
+
+func (rule *promotionalRule) Name() string {
+	return rule.rule.Name()
+}
+
+func (rule *promotionalRule) Description() string {
+	return rule.rule.Description() + " Only during the " + rule.promotion.Name + " promotion."
+}
+
+func (rule *promotionalRule) Evaluate(receipt *model.Receipt) RuleResult {
+	purchasedAt, err := localPurchaseTime(receipt)
+	if err != nil || !rule.promotion.IsActive(timezone.WallClock(purchasedAt)) {
+		return RuleResult{Points: 0, Reason: "promotion " + rule.promotion.Name + " is not active for this purchase"}
+	}
+	result := rule.rule.Evaluate(receipt)
+	result.Promotion = rule.promotion.Name
+	return result
+}
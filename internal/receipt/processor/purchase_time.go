@@ -0,0 +1,17 @@
+package processor
+
+import (
+	"receipt-processor-challenge/internal/receipt/model"
+	"receipt-processor-challenge/internal/receipt/timezone"
+	"time"
+)
+
+// Function to set the resolver used to find the local time a receipt was purchased at before the date and time rules are evaluated
+func SetTimeZoneResolver(resolver *timezone.Resolver) {
+	timezone.SetDefaultResolver(resolver)
+}
+
+// Function to get the purchase time of a receipt in the retailer's local time
+func localPurchaseTime(receipt *model.Receipt) (time.Time, error) {
+	return timezone.LocalPurchaseTime(receipt)
+}
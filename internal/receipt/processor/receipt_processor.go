@@ -2,32 +2,66 @@ package processor
 
 import (
 	"github.com/google/uuid"
-	"math"
 	"receipt-processor-challenge/internal/receipt/model"
 	"regexp"
-	"strconv"
 	"strings"
 	"time"
 )
 
-// Function to process a new receipt
-func ProcessReceipt(receipt *model.Receipt) *model.ProcessedReceipt {
-	points := getPoints(receipt)
-	processedReceipt := model.NewProcessedReceipt(uuid.New().String(), receipt, points)
-	return processedReceipt
+// Function to process a new receipt using the rules in the default registry
+func ProcessReceipt(receipt *model.Receipt) (*model.ProcessedReceipt, error) {
+	return ProcessReceiptWithRegistry(defaultRegistry, receipt)
 }
 
-// Function to calculate the points from a receipt
-func getPoints(receipt *model.Receipt) int {
+// Function to process a new receipt using the rules in the given registry, the rule set is captured once so a reload mid-processing doesn't affect it.
+// The processed receipt keeps its own copy of the receipt so later changes by the caller don't change what was processed
+func ProcessReceiptWithRegistry(registry *Registry, receipt *model.Receipt) (*model.ProcessedReceipt, error) {
+	ruleSet := registry.Snapshot()
+	points, breakdown, err := CalculatePoints(ruleSet, receipt)
+	if err != nil {
+		return nil, err
+	}
+	processedReceipt := model.NewProcessedReceipt(uuid.New().String(), receipt.Clone(), points, breakdown, ruleSet.Version)
+	return processedReceipt, nil
+}
+
+// Function to calculate the points and breakdown of a receipt under a specific rule set, the amounts are normalized to the default currency first
+func CalculatePoints(ruleSet RuleSet, receipt *model.Receipt) (int, []model.RuleBreakdown, error) {
+	normalizedReceipt, err := normalizeReceipt(receipt)
+	if err != nil {
+		return 0, nil, err
+	}
+	points, breakdown := getPoints(ruleSet, normalizedReceipt)
+	return points, breakdown, nil
+}
+
+// Function to calculate the points from a receipt by summing every rule in the rule set, then applying the category limits,
+// any matching retailer program and the receipt limits in that order, recording each contribution
+func getPoints(ruleSet RuleSet, receipt *model.Receipt) (int, []model.RuleBreakdown) {
 	points := 0
-	points += calculatePointFromRetailerName(receipt.RetailerName)
-	points += calculatePointsFromRoundTotalAmount(receipt.TotalAmount)
-	points += calculatePointsFromMultipleOfQuarterAmount(receipt.TotalAmount)
-	points += calculatePointsFromNumberItemsOnReceipt(receipt.Items)
-	points += calculatePointsForItemDescriptionLengthIsMultipleOfThree(receipt.Items)
-	points += calculatePointsFromPurchaseDayBeingOdd(receipt.PurchaseDate)
-	points += calculatePointsFromPurchaseTimeBeingBetweenTwoAndFourPM(receipt.PurchaseTime)
-	return points
+	breakdown := make([]model.RuleBreakdown, 0, len(ruleSet.Rules))
+	for _, rule := range ruleSet.Rules {
+		result := rule.Evaluate(receipt)
+		points += result.Points
+		breakdown = append(breakdown, model.RuleBreakdown{
+			Rule:      rule.Name(),
+			Points:    result.Points,
+			Reason:    result.Reason,
+			Promotion: result.Promotion,
+		})
+	}
+
+	points, categoryLimitBreakdown := applyCategoryLimits(ruleSet.Limits, breakdown, points)
+	breakdown = append(breakdown, categoryLimitBreakdown...)
+
+	points, programBreakdown := applyRetailerPrograms(ruleSet.RetailerPrograms, receipt, points)
+	if programBreakdown != nil {
+		breakdown = append(breakdown, *programBreakdown)
+	}
+
+	points, receiptLimitBreakdown := applyReceiptLimits(ruleSet.Limits, points)
+	breakdown = append(breakdown, receiptLimitBreakdown...)
+	return points, breakdown
 }
 
 // Function to calculate the points from the retailer name according to the business rules
@@ -37,7 +71,7 @@ func calculatePointFromRetailerName(retailerName string) int {
 }
 
 // Function to calculate the points from the total regarding round numbers according to the business rules
-func calculatePointsFromRoundTotalAmount(totalAmount string) int {
+func calculatePointsFromRoundTotalAmount(totalAmount model.Money) int {
 	if isRoundDollar(totalAmount) {
 		return 50
 	}
@@ -45,70 +79,94 @@ func calculatePointsFromRoundTotalAmount(totalAmount string) int {
 }
 
 // Function to calculate the points from the total regarding multiple of 0.25 according to the business rules
-func calculatePointsFromMultipleOfQuarterAmount(totalAmount string) int {
+func calculatePointsFromMultipleOfQuarterAmount(totalAmount model.Money) int {
 	if isMultipleOfQuarter(totalAmount) {
 		return 25
 	}
 	return 0
 }
 
-// Function to calculate the points from the number of items on the receipt according to the business rules
+// Function to calculate the points from the number of items on the receipt according to the business rules, returned items cancel out purchased items
 func calculatePointsFromNumberItemsOnReceipt(items []model.ReceiptItem) int {
-	pairs := len(items) / 2
+	pairs := netItemCount(items) / 2
 	return pairs * 5
 }
 
-// Function to calculate the points from the item description length being multiple of three according to the business rules
+// Function to calculate the points from the item description length being multiple of three according to the business rules,
+// returned items take away the points they would have earned and the total never goes below zero
 func calculatePointsForItemDescriptionLengthIsMultipleOfThree(items []model.ReceiptItem) int {
 	pointTotal := 0
 	for _, item := range items {
 		trimmedDescription := strings.TrimSpace(item.ShortDescription)
-		if len(trimmedDescription)%3 == 0 {
-			price, err := strconv.ParseFloat(item.Price, 64)
-			if err != nil {
-				continue
-			}
-			points := math.Ceil(price * 0.2)
-			pointTotal += int(points)
+		if len(trimmedDescription)%3 == 0 && item.Price.IsValid() {
+			pointTotal += multiplySignedPriceRoundingUp(item.Price, 2000)
 		}
 	}
-	return pointTotal
+	return max(pointTotal, 0)
 }
 
-// Function to calculate the points from the purchase day being odd according to the business rules
-func calculatePointsFromPurchaseDayBeingOdd(purchaseDate string) int {
-	date, err := time.Parse("2006-01-02", purchaseDate)
-	if err == nil && date.Day()%2 != 0 {
+// Function to calculate the points from the local purchase day being odd according to the business rules
+func calculatePointsFromPurchaseDayBeingOdd(purchasedAt time.Time) int {
+	if purchasedAt.Day()%2 != 0 {
 		return 6
 	}
 	return 0
 }
 
-// Function to calculate the points from the purchase time being between 2 PM and 4 PM according to the business rules
-func calculatePointsFromPurchaseTimeBeingBetweenTwoAndFourPM(purchaseTime string) int {
-	dateTime := "2025-01-01 " + purchaseTime
-	parsedTime, err := time.Parse("2006-01-02 15:04", dateTime)
-
-	if err == nil && parsedTime.Hour() >= 14 && parsedTime.Hour() < 16 {
+// Function to calculate the points from the local purchase time being between 2 PM and 4 PM according to the business rules
+func calculatePointsFromPurchaseTimeBeingBetweenTwoAndFourPM(purchasedAt time.Time) int {
+	if purchasedAt.Hour() >= 14 && purchasedAt.Hour() < 16 {
 		return 10
 	}
 	return 0
 }
 
 // Function to check if a value is a multiple of 0.25
-func isMultipleOfQuarter(value string) bool {
-	convertedFloat, err := strconv.ParseFloat(value, 64)
-	if err != nil {
-		return false
-	}
-	return int(convertedFloat*100)%25 == 0
+func isMultipleOfQuarter(value model.Money) bool {
+	return isMultipleOfCents(value, 25)
+}
+
+// Function to check if a value is a multiple of the given number of cents
+func isMultipleOfCents(value model.Money, cents int64) bool {
+	return value.IsValid() && value.IsMultipleOf(model.NewMoney(cents))
 }
 
 // Function to check if a value is a round dollar value ending in .00
-func isRoundDollar(value string) bool {
-	convertedFloat, err := strconv.ParseFloat(value, 64)
-	if err != nil {
-		return false
+func isRoundDollar(value model.Money) bool {
+	return value.IsValid() && value.IsRoundDollar()
+}
+
+// Function to multiply a price by a multiplier given in ten-thousandths, so 0.2 is 2000, and round up to the nearest integer
+func multiplyPriceRoundingUp(price model.Money, multiplierTenThousandths int64) int {
+	return int(ceilDiv(price.Cents()*multiplierTenThousandths, 100*10000))
+}
+
+// Function to multiply a price that may be negative, rounding away from zero so a returned item takes away exactly the points the purchase earned
+func multiplySignedPriceRoundingUp(price model.Money, multiplierTenThousandths int64) int {
+	if price.IsNegative() {
+		return -multiplyPriceRoundingUp(price.Abs(), multiplierTenThousandths)
+	}
+	return multiplyPriceRoundingUp(price, multiplierTenThousandths)
+}
+
+// Function to count the purchased item lines minus the returned item lines, never going below zero
+func netItemCount(items []model.ReceiptItem) int {
+	count := 0
+	for _, item := range items {
+		if item.IsReturn() {
+			count--
+		} else {
+			count++
+		}
+	}
+	return max(count, 0)
+}
+
+// Function to divide two integers rounding towards positive infinity
+func ceilDiv(dividend int64, divisor int64) int64 {
+	quotient := dividend / divisor
+	if dividend%divisor != 0 && (dividend > 0) == (divisor > 0) {
+		quotient++
 	}
-	return int(convertedFloat*100)%100 == 0
+	return quotient
 }
@@ -0,0 +1,150 @@
+package processor
+
+import (
+	"fmt"
+	"math"
+	"receipt-processor-challenge/internal/receipt/model"
+	"receipt-processor-challenge/pkg/config"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type RetailerMatch string
+
+const (
+	MatchExact           RetailerMatch = "exact"
+	MatchCaseInsensitive RetailerMatch = "case-insensitive"
+	MatchRegex           RetailerMatch = "regex"
+)
+
+// a partner retailer bonus program applied on top of the points from the rules, such as 2x points or 100 bonus points
+type RetailerProgram struct {
+	Name     string
+	Match    RetailerMatch
+	Retailer string
+	Priority int
+	// the multiplier in hundredths, so 2x is 200
+	multiplierHundredths int64
+	bonus                int
+	pattern              *regexp.Regexp
+}
+
+// the order matches are tried in when two programs have the same priority
+var matchSpecificity = map[RetailerMatch]int{
+	MatchExact:           0,
+	MatchCaseInsensitive: 1,
+	MatchRegex:           2,
+}
+
+// Function to create a new retailer program, a multiplier of 1 leaves the points unchanged and the bonus is added after multiplying
+func NewRetailerProgram(name string, match RetailerMatch, retailer string, multiplier float64, bonus int, priority int) (RetailerProgram, error) {
+	program := RetailerProgram{
+		Name:                 name,
+		Match:                match,
+		Retailer:             retailer,
+		Priority:             priority,
+		multiplierHundredths: int64(math.Round(multiplier * 100)),
+		bonus:                bonus,
+	}
+	if name == "" {
+		return RetailerProgram{}, fmt.Errorf("retailer program name is required")
+	}
+	if retailer == "" {
+		return RetailerProgram{}, fmt.Errorf("retailer program %s needs a retailer to match", name)
+	}
+	if program.multiplierHundredths < 0 {
+		return RetailerProgram{}, fmt.Errorf("retailer program %s has a negative multiplier %v", name, multiplier)
+	}
+
+	switch match {
+	case MatchExact, MatchCaseInsensitive:
+	case MatchRegex:
+		pattern, err := regexp.Compile(retailer)
+		if err != nil {
+			return RetailerProgram{}, fmt.Errorf("retailer program %s has an invalid pattern: %w", name, err)
+		}
+		program.pattern = pattern
+	default:
+		return RetailerProgram{}, fmt.Errorf("retailer program %s has an unknown match type %s", name, match)
+	}
+	return program, nil
+}
+
+// Function to build the retailer programs declared in a rule set config
+func buildRetailerPrograms(programConfigs []config.RetailerProgramConfig) ([]RetailerProgram, error) {
+	programs := make([]RetailerProgram, 0, len(programConfigs))
+	for _, programConfig := range programConfigs {
+		match := RetailerMatch(programConfig.Match)
+		if match == "" {
+			match = MatchExact
+		}
+		multiplier := programConfig.Multiplier
+		if multiplier == 0 {
+			multiplier = 1
+		}
+		program, err := NewRetailerProgram(programConfig.Name, match, programConfig.Retailer, multiplier, programConfig.Bonus, programConfig.Priority)
+		if err != nil {
+			return nil, err
+		}
+		programs = append(programs, program)
+	}
+	return programs, nil
+}
+
+// checks if the program applies to a retailer name
+func (program RetailerProgram) Matches(retailerName string) bool {
+	switch program.Match {
+	case MatchExact:
+		return retailerName == program.Retailer
+	case MatchCaseInsensitive:
+		return strings.EqualFold(strings.TrimSpace(retailerName), strings.TrimSpace(program.Retailer))
+	case MatchRegex:
+		return program.pattern.MatchString(retailerName)
+	default:
+		return false
+	}
+}
+
+// describes what the program awards such as 2x points and 100 bonus points
+func (program RetailerProgram) Description() string {
+	var awards []string
+	if program.multiplierHundredths != 100 {
+		awards = append(awards, strconv.FormatFloat(float64(program.multiplierHundredths)/100, 'f', -1, 64)+"x points")
+	}
+	if program.bonus != 0 {
+		awards = append(awards, fmt.Sprintf("%d bonus points", program.bonus))
+	}
+	if len(awards) == 0 {
+		return "no extra points"
+	}
+	return strings.Join(awards, " and ")
+}
+
+// Function to apply the highest precedence retailer program matching the receipt to the points from the rules.
+// Programs are ranked by priority, highest first, then exact matches before case-insensitive matches before regex matches,
+// then the order they were declared in. Only the first matching program applies
+func applyRetailerPrograms(programs []RetailerProgram, receipt *model.Receipt, points int) (int, *model.RuleBreakdown) {
+	rankedPrograms := make([]RetailerProgram, len(programs))
+	copy(rankedPrograms, programs)
+	sort.SliceStable(rankedPrograms, func(i, j int) bool {
+		if rankedPrograms[i].Priority != rankedPrograms[j].Priority {
+			return rankedPrograms[i].Priority > rankedPrograms[j].Priority
+		}
+		return matchSpecificity[rankedPrograms[i].Match] < matchSpecificity[rankedPrograms[j].Match]
+	})
+
+	for _, program := range rankedPrograms {
+		if !program.Matches(receipt.RetailerName) {
+			continue
+		}
+		adjustedPoints := int(int64(points)*program.multiplierHundredths/100) + program.bonus
+		return adjustedPoints, &model.RuleBreakdown{
+			Rule:   program.Name,
+			Points: adjustedPoints - points,
+			Reason: fmt.Sprintf("%s receipts earn %s", receipt.RetailerName, program.Description()),
+		}
+	}
+	return points, nil
+}
@@ -0,0 +1,44 @@
+package processor
+
+import (
+	"receipt-processor-challenge/internal/receipt/model"
+)
+
+type Rule interface {
+	Name() string
+	Description() string
+	Evaluate(receipt *model.Receipt) RuleResult
+}
+
+type RuleResult struct {
+	Points    int
+	Reason    string
+	Promotion string
+}
+
+type ruleFunc struct {
+	name        string
+	description string
+	evaluate    func(receipt *model.Receipt) RuleResult
+}
+
+// Function to create a new rule from a name, description and evaluation function
+func NewRule(name string, description string, evaluate func(receipt *model.Receipt) RuleResult) Rule {
+	return &ruleFunc{
+		name:        name,
+		description: description,
+		evaluate:    evaluate,
+	}
+}
+
+func (rule *ruleFunc) Name() string {
+	return rule.name
+}
+
+func (rule *ruleFunc) Description() string {
+	return rule.description
+}
+
+func (rule *ruleFunc) Evaluate(receipt *model.Receipt) RuleResult {
+	return rule.evaluate(receipt)
+}
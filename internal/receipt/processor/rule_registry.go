@@ -0,0 +1,271 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+type Registry struct {
+	version          string
+	checksum         string
+	rules            []Rule
+	retailerPrograms []RetailerProgram
+	limits           []PointsLimit
+	versions         map[string]RuleSet
+	mu               sync.RWMutex
+}
+
+// a version of the rules, the checksum identifies the rule file a version was loaded from
+type RuleSet struct {
+	Version          string
+	Checksum         string
+	Rules            []Rule
+	RetailerPrograms []RetailerProgram
+	Limits           []PointsLimit
+}
+
+const BuiltinRuleSetVersion = "builtin"
+
+var ErrRuleSetNotFound = errors.New("rule set was not found")
+
+var defaultRegistry = NewRegistry(BuiltinRuleSetVersion, DefaultRules()...)
+
+// Function to create a new rule registry for a rule set version containing the given rules in order
+func NewRegistry(version string, rules ...Rule) *Registry {
+	registry := &Registry{
+		version:  version,
+		versions: make(map[string]RuleSet),
+	}
+	for _, rule := range rules {
+		if err := registry.Register(rule); err != nil {
+			panic(err)
+		}
+	}
+	registry.versions[version] = registry.Snapshot()
+	return registry
+}
+
+// Function to retrieve the registry used when processing receipts
+func DefaultRegistry() *Registry {
+	return defaultRegistry
+}
+
+// adds a rule to the end of the registry
+func (registry *Registry) Register(rule Rule) error {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	if registry.indexOf(rule.Name()) >= 0 {
+		return fmt.Errorf("rule with name %s is already registered", rule.Name())
+	}
+	registry.rules = append(registry.rules, rule)
+	return nil
+}
+
+// removes a rule from the registry by it's name
+func (registry *Registry) Remove(name string) error {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	index := registry.indexOf(name)
+	if index < 0 {
+		return fmt.Errorf("rule with name %s was not found", name)
+	}
+	registry.rules = append(registry.rules[:index:index], registry.rules[index+1:]...)
+	return nil
+}
+
+// reorders the registry so the rules run in the order of the given names, every registered rule must be named once
+func (registry *Registry) Reorder(names ...string) error {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	if len(names) != len(registry.rules) {
+		return fmt.Errorf("expected %d rule names but got %d", len(registry.rules), len(names))
+	}
+
+	reordered := make([]Rule, 0, len(names))
+	for _, name := range names {
+		index := registry.indexOf(name)
+		if index < 0 {
+			return fmt.Errorf("rule with name %s was not found", name)
+		}
+		for _, rule := range reordered {
+			if rule.Name() == name {
+				return fmt.Errorf("rule with name %s was listed more than once", name)
+			}
+		}
+		reordered = append(reordered, registry.rules[index])
+	}
+	registry.rules = reordered
+	return nil
+}
+
+// adds a retailer program to the registry
+func (registry *Registry) RegisterRetailerProgram(program RetailerProgram) error {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	for _, registeredProgram := range registry.retailerPrograms {
+		if registeredProgram.Name == program.Name {
+			return fmt.Errorf("retailer program with name %s is already registered", program.Name)
+		}
+	}
+	registry.retailerPrograms = append(registry.retailerPrograms, program)
+	return nil
+}
+
+// removes a retailer program from the registry by it's name
+func (registry *Registry) RemoveRetailerProgram(name string) error {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	for index, program := range registry.retailerPrograms {
+		if program.Name == name {
+			registry.retailerPrograms = append(registry.retailerPrograms[:index:index], registry.retailerPrograms[index+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("retailer program with name %s was not found", name)
+}
+
+// adds a points limit to the end of the registry, limits are applied in the order they were registered
+func (registry *Registry) RegisterLimit(limit PointsLimit) error {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	for _, registeredLimit := range registry.limits {
+		if registeredLimit.Name == limit.Name {
+			return fmt.Errorf("points limit with name %s is already registered", limit.Name)
+		}
+	}
+	registry.limits = append(registry.limits, limit)
+	return nil
+}
+
+// removes a points limit from the registry by it's name
+func (registry *Registry) RemoveLimit(name string) error {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	for index, limit := range registry.limits {
+		if limit.Name == name {
+			registry.limits = append(registry.limits[:index:index], registry.limits[index+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("points limit with name %s was not found", name)
+}
+
+// atomically replaces every registered rule, retailer program and points limit with those in the rule set under the rule set's version.
+// A version the registry has already held can't be reused for different rules, as the receipts scored with it would no longer match its rules
+func (registry *Registry) Replace(ruleSet RuleSet) error {
+	replacement := &Registry{version: ruleSet.Version, checksum: ruleSet.Checksum}
+	for _, rule := range ruleSet.Rules {
+		if err := replacement.Register(rule); err != nil {
+			return err
+		}
+	}
+	for _, program := range ruleSet.RetailerPrograms {
+		if err := replacement.RegisterRetailerProgram(program); err != nil {
+			return err
+		}
+	}
+	for _, limit := range ruleSet.Limits {
+		if err := replacement.RegisterLimit(limit); err != nil {
+			return err
+		}
+	}
+
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	if heldRuleSet, exists := registry.versions[ruleSet.Version]; exists && heldRuleSet.Checksum != ruleSet.Checksum {
+		return fmt.Errorf("rule set version %s is already used by different rules, the changed rules need a new version", ruleSet.Version)
+	}
+	registry.version = ruleSet.Version
+	registry.checksum = ruleSet.Checksum
+	registry.rules = replacement.rules
+	registry.retailerPrograms = replacement.retailerPrograms
+	registry.limits = replacement.limits
+	registry.versions[ruleSet.Version] = replacement.Snapshot()
+	return nil
+}
+
+// takes a consistent copy of the rule set version, rules, retailer programs and limits, so later changes to the registry don't affect it
+func (registry *Registry) Snapshot() RuleSet {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	rules := make([]Rule, len(registry.rules))
+	copy(rules, registry.rules)
+	retailerPrograms := make([]RetailerProgram, len(registry.retailerPrograms))
+	copy(retailerPrograms, registry.retailerPrograms)
+	limits := make([]PointsLimit, len(registry.limits))
+	copy(limits, registry.limits)
+	return RuleSet{
+		Version:          registry.version,
+		Checksum:         registry.checksum,
+		Rules:            rules,
+		RetailerPrograms: retailerPrograms,
+		Limits:           limits,
+	}
+}
+
+// finds a rule set the registry has held by it's version, the current version reflects any rules registered since it was loaded
+func (registry *Registry) RuleSet(version string) (RuleSet, error) {
+	if version == registry.Version() {
+		return registry.Snapshot(), nil
+	}
+
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	ruleSet, exists := registry.versions[version]
+	if !exists {
+		return RuleSet{}, fmt.Errorf("%w for version %s", ErrRuleSetNotFound, version)
+	}
+	return ruleSet, nil
+}
+
+// lists the versions of every rule set the registry has held
+func (registry *Registry) Versions() []string {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	versions := make([]string, 0, len(registry.versions))
+	for version := range registry.versions {
+		versions = append(versions, version)
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+// gets the version of the rule set currently in the registry
+func (registry *Registry) Version() string {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	return registry.version
+}
+
+// lists the registered rules in the order they are evaluated
+func (registry *Registry) Rules() []Rule {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	rules := make([]Rule, len(registry.rules))
+	copy(rules, registry.rules)
+	return rules
+}
+
+// finds the position of a rule by it's name, the caller must hold the lock
+func (registry *Registry) indexOf(name string) int {
+	for index, rule := range registry.rules {
+		if rule.Name() == name {
+			return index
+		}
+	}
+	return -1
+}
@@ -0,0 +1,185 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"receipt-processor-challenge/internal/receipt/model"
+	"receipt-processor-challenge/pkg/db"
+)
+
+type InMemoryRepository struct {
+	store  *db.Store[model.ProcessedReceipt]
+	logger *logrus.Logger
+}
+
+const (
+	fingerprintIndex     = "fingerprint"
+	retailerDateIndex    = "retailer_date"
+	DefaultSnapshotEvery = 1000
+)
+
+// Function to create a new Processed Receipt Repository that keeps the receipts in memory, they are lost when the application stops
+func NewInMemoryRepository(logger *logrus.Logger) *InMemoryRepository {
+	store := db.NewStore[model.ProcessedReceipt]()
+	if err := addReceiptIndexes(store); err != nil {
+		logger.Fatalf("failed to index receipts: %v", err)
+	}
+	return &InMemoryRepository{
+		store:  store,
+		logger: logger,
+	}
+}
+
+// Function to create a new Processed Receipt Repository that keeps the receipts in memory and writes every change to a log in a directory,
+// the receipts in the log are loaded when it is created so they survive restarts. The log is compacted into a snapshot every so many changes
+func NewLoggedInMemoryRepository(dir string, snapshotEvery int, logger *logrus.Logger) (*InMemoryRepository, error) {
+	store, err := db.OpenStore(dir, db.LogOptions[model.ProcessedReceipt]{
+		Codec:         db.JSONCodec[model.ProcessedReceipt](),
+		SnapshotEvery: snapshotEvery,
+		SyncWrites:    true,
+		OnSnapshotError: func(err error) {
+			logger.Errorf("failed to snapshot the receipt log in %s: %v", dir, err)
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the receipt log in %s: %w", dir, err)
+	}
+	if err := addReceiptIndexes(store); err != nil {
+		store.Close()
+		return nil, fmt.Errorf("failed to index receipts: %w", err)
+	}
+	logger.Infof("loaded %d receipts from the receipt log in %s", len(store.List()), dir)
+	return &InMemoryRepository{
+		store:  store,
+		logger: logger,
+	}, nil
+}
+
+// Function to save a new processed receipt to the dataset for persistence
+func (receiptRepository *InMemoryRepository) Save(ctx context.Context, receipt *model.ProcessedReceipt) (model.ProcessedReceipt, error) {
+	logger := receiptRepository.logger
+	logger.Infof("saving processed receipt with id %v to the database", receipt.ID())
+	savedEntity, err := receiptRepository.store.Save(*receipt)
+	if err != nil {
+		logger.Errorf("failed to save receipt with id %v to the database: %v", receipt.ID(), err)
+		return savedEntity, duplicateReceiptErrorOr(err)
+	}
+	return savedEntity, nil
+}
+
+// Function to update an existing processed receipt in the dataset
+func (receiptRepository *InMemoryRepository) Update(ctx context.Context, receipt *model.ProcessedReceipt) (model.ProcessedReceipt, error) {
+	logger := receiptRepository.logger
+	logger.Infof("updating processed receipt with id %v in the database", receipt.ID())
+	updatedEntity, err := receiptRepository.store.Update(*receipt)
+	if err != nil {
+		logger.Errorf("failed to update receipt with id %v in the database: %v", receipt.ID(), err)
+		return updatedEntity, duplicateReceiptErrorOr(err)
+	}
+	return updatedEntity, nil
+}
+
+// Function to find the processed receipt with a fingerprint in the dataset, a nil receipt is returned when there is none
+func (receiptRepository *InMemoryRepository) FindByFingerprint(ctx context.Context, fingerprint string) (*model.ProcessedReceipt, error) {
+	log := receiptRepository.logger
+	log.Infof("fetching receipt with fingerprint %v from the database", fingerprint)
+
+	receipt, err := receiptRepository.store.FindByUniqueIndex(fingerprintIndex, fingerprint)
+	if errors.Is(err, db.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		log.Errorf("failed to fetch receipt with fingerprint %v from the database: %v", fingerprint, err)
+		return nil, err
+	}
+	return &receipt, nil
+}
+
+// Function to find the processed receipts from a retailer on a purchase date, retailer names are compared ignoring case and extra spaces
+func (receiptRepository *InMemoryRepository) FindByRetailerAndDate(ctx context.Context, retailerName string, purchaseDate string) ([]model.ProcessedReceipt, error) {
+	log := receiptRepository.logger
+	log.Infof("fetching receipts from retailer %v on %v from the database", retailerName, purchaseDate)
+
+	receipts, err := receiptRepository.store.FindByIndex(retailerDateIndex, db.IndexKey(retailerKey(retailerName), purchaseDate))
+	if err != nil {
+		log.Errorf("failed to fetch receipts from retailer %v on %v from the database: %v", retailerName, purchaseDate, err)
+	}
+	return receipts, err
+}
+
+// Function to find the processed receipts from a retailer with a purchase date from the first date up to but not including the last date,
+// sorted by purchase date. Only the receipts in the range are read from the ordered retailer and date index
+func (receiptRepository *InMemoryRepository) FindByRetailerBetweenDates(ctx context.Context, retailerName string, fromDate string, beforeDate string) ([]model.ProcessedReceipt, error) {
+	log := receiptRepository.logger
+	log.Infof("fetching receipts from retailer %v from %v before %v from the database", retailerName, fromDate, beforeDate)
+
+	if err := checkDateRange(fromDate, beforeDate); err != nil {
+		return nil, err
+	}
+	key := retailerKey(retailerName)
+	receipts, err := receiptRepository.store.FindByRange(retailerDateIndex, db.IndexKey(key, fromDate), db.IndexKey(key, beforeDate))
+	if err != nil {
+		log.Errorf("failed to fetch receipts from retailer %v from %v before %v from the database: %v", retailerName, fromDate, beforeDate, err)
+	}
+	return receipts, err
+}
+
+// Function to find every processed receipt in the dataset
+func (receiptRepository *InMemoryRepository) FindAll(ctx context.Context) ([]model.ProcessedReceipt, error) {
+	log := receiptRepository.logger
+	log.Infof("fetching all receipts from the database")
+	return receiptRepository.store.List(), nil
+}
+
+// Function to find a processed receipt in the dataset by it's id
+func (receiptRepository *InMemoryRepository) FindById(ctx context.Context, id uuid.UUID) (model.ProcessedReceipt, error) {
+	log := receiptRepository.logger
+
+	log.Infof("fetching receipt with id %v from the database", id)
+
+	receipt, err := receiptRepository.store.FindById(id.String())
+	if err != nil {
+		log.Errorf("failed to fetch receipt with id %v from the database: %v", id, err)
+	}
+	return receipt, err
+}
+
+// Function to delete a processed receipt from the dataset by it's id
+func (receiptRepository *InMemoryRepository) DeleteById(ctx context.Context, id uuid.UUID) error {
+	log := receiptRepository.logger
+	log.Infof("deleting receipt with id %v from the database", id)
+
+	err := receiptRepository.store.DeleteById(id.String())
+	if err != nil {
+		log.Errorf("failed to delete receipt with id %v from the database: %v", id, err)
+	}
+	return err
+}
+
+// Function to add the indexes receipts are looked up by, the fingerprint index is unique so a receipt can't be stored twice.
+// The retailer and date index is ordered, so it can also find the receipts from a retailer over a range of dates
+func addReceiptIndexes(store *db.Store[model.ProcessedReceipt]) error {
+	if err := store.AddUniqueIndex(fingerprintIndex, model.ProcessedReceipt.Fingerprint); err != nil {
+		return err
+	}
+	return store.AddOrderedIndex(retailerDateIndex, func(receipt model.ProcessedReceipt) string {
+		return db.IndexKey(retailerKey(receipt.Receipt().RetailerName), receipt.Receipt().PurchaseDate)
+	})
+}
+
+// Function to close the receipt log, if the receipts are written to one
+func (receiptRepository *InMemoryRepository) Close() error {
+	return receiptRepository.store.Close()
+}
+
+// Function to turn a duplicate fingerprint error from the store into a DuplicateReceiptError, other errors are returned as they are
+func duplicateReceiptErrorOr(err error) error {
+	var duplicateKeyError *db.DuplicateKeyError
+	if errors.As(err, &duplicateKeyError) && duplicateKeyError.Index == fingerprintIndex {
+		return &DuplicateReceiptError{OriginalID: duplicateKeyError.ExistingID}
+	}
+	return err
+}
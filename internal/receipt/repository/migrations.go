@@ -0,0 +1,16 @@
+package repository
+
+import (
+	"embed"
+	"receipt-processor-challenge/pkg/db"
+)
+
+// the versioned schema of each sql backend, new migrations are added as the next numbered file and applied migrations are never edited
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+// Function to read the migrations of a sql backend from the migrations directory of the same name
+func backendMigrations(backend string) ([]db.Migration, error) {
+	return db.LoadMigrations(migrationFiles, "migrations/"+backend)
+}
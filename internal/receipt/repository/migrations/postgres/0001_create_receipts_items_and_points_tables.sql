@@ -0,0 +1,44 @@
+CREATE TABLE receipts (
+    id UUID PRIMARY KEY,
+    retailer TEXT NOT NULL,
+    retailer_key TEXT NOT NULL,
+    purchase_date TEXT NOT NULL,
+    purchase_time TEXT NOT NULL,
+    time_zone TEXT NOT NULL DEFAULT '',
+    total_cents BIGINT NOT NULL,
+    currency TEXT NOT NULL DEFAULT '',
+    subtotal_cents BIGINT,
+    tax_cents BIGINT,
+    tip_cents BIGINT,
+    discounts JSONB NOT NULL DEFAULT '[]',
+    points INTEGER NOT NULL,
+    rule_set_version TEXT NOT NULL DEFAULT '',
+    fingerprint TEXT CONSTRAINT receipts_fingerprint_key UNIQUE,
+    fraud_review JSONB NOT NULL DEFAULT '{}',
+    recalculations JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX receipts_retailer_date ON receipts (retailer_key, purchase_date);
+
+CREATE TABLE items (
+    receipt_id UUID NOT NULL REFERENCES receipts (id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    short_description TEXT NOT NULL,
+    price_cents BIGINT NOT NULL,
+    sku TEXT NOT NULL DEFAULT '',
+    upc TEXT NOT NULL DEFAULT '',
+    quantity INTEGER NOT NULL DEFAULT 0,
+    unit_price_cents BIGINT,
+    PRIMARY KEY (receipt_id, position)
+);
+
+CREATE TABLE points (
+    receipt_id UUID NOT NULL REFERENCES receipts (id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    rule TEXT NOT NULL,
+    points INTEGER NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    promotion TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (receipt_id, position)
+);
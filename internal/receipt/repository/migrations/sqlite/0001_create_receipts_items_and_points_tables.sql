@@ -0,0 +1,44 @@
+CREATE TABLE receipts (
+    id TEXT PRIMARY KEY,
+    retailer TEXT NOT NULL,
+    retailer_key TEXT NOT NULL,
+    purchase_date TEXT NOT NULL,
+    purchase_time TEXT NOT NULL,
+    time_zone TEXT NOT NULL DEFAULT '',
+    total_cents INTEGER NOT NULL,
+    currency TEXT NOT NULL DEFAULT '',
+    subtotal_cents INTEGER,
+    tax_cents INTEGER,
+    tip_cents INTEGER,
+    discounts TEXT NOT NULL DEFAULT '[]',
+    points INTEGER NOT NULL,
+    rule_set_version TEXT NOT NULL DEFAULT '',
+    fingerprint TEXT UNIQUE,
+    fraud_review TEXT NOT NULL DEFAULT '{}',
+    recalculations TEXT NOT NULL DEFAULT '[]',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX receipts_retailer_date ON receipts (retailer_key, purchase_date);
+
+CREATE TABLE items (
+    receipt_id TEXT NOT NULL REFERENCES receipts (id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    short_description TEXT NOT NULL,
+    price_cents INTEGER NOT NULL,
+    sku TEXT NOT NULL DEFAULT '',
+    upc TEXT NOT NULL DEFAULT '',
+    quantity INTEGER NOT NULL DEFAULT 0,
+    unit_price_cents INTEGER,
+    PRIMARY KEY (receipt_id, position)
+);
+
+CREATE TABLE points (
+    receipt_id TEXT NOT NULL REFERENCES receipts (id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    rule TEXT NOT NULL,
+    points INTEGER NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    promotion TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (receipt_id, position)
+);
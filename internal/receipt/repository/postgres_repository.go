@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgconn"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/sirupsen/logrus"
+	"receipt-processor-challenge/pkg/config"
+)
+
+const PostgresBackend = "postgres"
+
+const (
+	// the postgres error code of a unique constraint violation
+	uniqueViolationCode = "23505"
+	// an arbitrary key for the advisory lock that keeps instances starting at the same time from migrating the database together
+	migrationLockKey = 7203316
+)
+
+var postgresDialect = sqlDialect{
+	rebind: numberedPlaceholders,
+	isDuplicateFingerprint: func(err error) bool {
+		var postgresError *pgconn.PgError
+		return errors.As(err, &postgresError) && postgresError.Code == uniqueViolationCode && postgresError.ConstraintName == "receipts_fingerprint_key"
+	},
+	orderBy: "created_at, id",
+}
+
+// Function to create a new Processed Receipt Repository that keeps the receipts in a postgres database, with a connection pool sized by the config.
+// The schema is migrated to the latest version when it is created
+func NewPostgresRepository(postgresConfig config.PostgresConfig, logger *logrus.Logger) (*SQLRepository, error) {
+	if postgresConfig.DSN == "" {
+		return nil, fmt.Errorf("POSTGRES_DSN must be set to store receipts in postgres")
+	}
+	database, err := sql.Open("pgx", postgresConfig.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open postgres database: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), postgresConfig.ConnectTimeout)
+	defer cancel()
+	if err := database.PingContext(ctx); err != nil {
+		database.Close()
+		return nil, fmt.Errorf("failed to connect to postgres database: %w", err)
+	}
+
+	receiptRepository, err := migrateWithLock(database, logger)
+	if err != nil {
+		database.Close()
+		return nil, err
+	}
+	// the pool is only limited after migrating, since the migrations need a connection besides the one holding the lock
+	database.SetMaxOpenConns(postgresConfig.MaxOpenConns)
+	database.SetMaxIdleConns(postgresConfig.MaxIdleConns)
+	database.SetConnMaxLifetime(postgresConfig.ConnMaxLifetime)
+	database.SetConnMaxIdleTime(postgresConfig.ConnMaxIdleTime)
+	return receiptRepository, nil
+}
+
+// Function to migrate the database while holding an advisory lock, so only one instance migrates it and the others wait until it is done
+func migrateWithLock(database *sql.DB, logger *logrus.Logger) (*SQLRepository, error) {
+	ctx := context.Background()
+	lockConnection, err := database.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to postgres database: %w", err)
+	}
+	defer lockConnection.Close()
+
+	if _, err := lockConnection.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockKey); err != nil {
+		return nil, fmt.Errorf("failed to lock the postgres database for migrations: %w", err)
+	}
+	defer lockConnection.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", migrationLockKey)
+
+	return newSQLRepository(ctx, database, PostgresBackend, postgresDialect, logger)
+}
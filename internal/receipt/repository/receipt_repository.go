@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"receipt-processor-challenge/internal/receipt/model"
+	"receipt-processor-challenge/pkg/config"
+	"strings"
+)
+
+type ReceiptRepository interface {
+	// saves a new processed receipt, failing with a DuplicateReceiptError when a receipt with the same fingerprint is stored
+	Save(ctx context.Context, receipt *model.ProcessedReceipt) (model.ProcessedReceipt, error)
+	// replaces a stored processed receipt, such as after it was recalculated
+	Update(ctx context.Context, receipt *model.ProcessedReceipt) (model.ProcessedReceipt, error)
+	FindAll(ctx context.Context) ([]model.ProcessedReceipt, error)
+	FindById(ctx context.Context, id uuid.UUID) (model.ProcessedReceipt, error)
+	// finds the receipt with a fingerprint, returning nil when there is none
+	FindByFingerprint(ctx context.Context, fingerprint string) (*model.ProcessedReceipt, error)
+	// finds the receipts from a retailer on a purchase date, retailer names are compared ignoring case and extra spaces
+	FindByRetailerAndDate(ctx context.Context, retailerName string, purchaseDate string) ([]model.ProcessedReceipt, error)
+	// finds the receipts from a retailer with a purchase date on or after fromDate and before beforeDate, sorted by purchase date.
+	// beforeDate itself is left out, so the receipts of January are found from 2022-01-01 before 2022-02-01
+	FindByRetailerBetweenDates(ctx context.Context, retailerName string, fromDate string, beforeDate string) ([]model.ProcessedReceipt, error)
+	DeleteById(ctx context.Context, id uuid.UUID) error
+}
+
+const InMemoryBackend = "memory"
+
+var ErrDuplicateReceipt = errors.New("receipt was already processed")
+
+type DuplicateReceiptError struct {
+	OriginalID string
+}
+
+func (err *DuplicateReceiptError) Error() string {
+	return fmt.Sprintf("receipt was already processed with id %s", err.OriginalID)
+}
+
+func (err *DuplicateReceiptError) Unwrap() error {
+	return ErrDuplicateReceipt
+}
+
+// Function to create the repository for the configured storage backend, receipts are kept in memory when no backend is configured,
+// and also written to a log when a log directory is configured
+func NewReceiptRepository(storageConfig *config.StorageConfig, logger *logrus.Logger) (ReceiptRepository, error) {
+	switch storageConfig.Backend {
+	case "", InMemoryBackend:
+		if storageConfig.LogDir == "" {
+			return NewInMemoryRepository(logger), nil
+		}
+		snapshotEvery := storageConfig.SnapshotEvery
+		if snapshotEvery == 0 {
+			snapshotEvery = DefaultSnapshotEvery
+		}
+		return NewLoggedInMemoryRepository(storageConfig.LogDir, snapshotEvery, logger)
+	case SQLiteBackend:
+		return NewSQLiteRepository(storageConfig.SQLitePath, logger)
+	case PostgresBackend:
+		return NewPostgresRepository(storageConfig.Postgres, logger)
+	default:
+		return nil, fmt.Errorf("storage backend %s is not supported", storageConfig.Backend)
+	}
+}
+
+// Function to get the key receipts are looked up by retailer with, which ignores case and extra spaces
+func retailerKey(retailerName string) string {
+	return strings.ToLower(strings.Join(strings.Fields(retailerName), " "))
+}
+
+// Function to check a range of purchase dates has both the first date it includes and the date it ends before
+func checkDateRange(fromDate string, beforeDate string) error {
+	if fromDate == "" || beforeDate == "" {
+		return fmt.Errorf("a range of purchase dates needs a date to start from and a date to end before but got %q and %q", fromDate, beforeDate)
+	}
+	return nil
+}
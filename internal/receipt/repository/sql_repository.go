@@ -0,0 +1,456 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"receipt-processor-challenge/internal/receipt/model"
+	"receipt-processor-challenge/pkg/db"
+	"sort"
+	"strings"
+)
+
+// a repository that keeps the receipts in a sql database, in a receipts table with their lines in an items table and the points each rule awarded in a points table
+type SQLRepository struct {
+	database *sql.DB
+	dialect  sqlDialect
+	logger   *logrus.Logger
+}
+
+// the differences between the sql databases the receipts can be kept in, the queries are written with ? placeholders
+type sqlDialect struct {
+	// rewrites the ? placeholders of a query into the ones the database uses
+	rebind func(query string) string
+	// checks if an error is a violation of the unique constraint on the receipt fingerprint
+	isDuplicateFingerprint func(err error) bool
+	// the columns receipts are sorted by so they are found in the order they were saved
+	orderBy string
+}
+
+const receiptColumns = `id, retailer, retailer_key, purchase_date, purchase_time, time_zone, total_cents, currency, subtotal_cents, tax_cents, tip_cents,
+	discounts, points, rule_set_version, fingerprint, fraud_review, recalculations`
+
+// Function to migrate a sql database to the latest version of a backend's schema and create a repository on it
+func newSQLRepository(ctx context.Context, database *sql.DB, backend string, dialect sqlDialect, logger *logrus.Logger) (*SQLRepository, error) {
+	migrations, err := backendMigrations(backend)
+	if err != nil {
+		return nil, err
+	}
+	appliedMigrations, err := db.Migrate(ctx, database, migrations)
+	if err != nil {
+		return nil, fmt.Errorf("failed to migrate the %s database: %w", backend, err)
+	}
+	for _, migration := range appliedMigrations {
+		logger.Infof("applied migration %d to the %s database: %s", migration.Version, backend, migration.Description)
+	}
+	return &SQLRepository{
+		database: database,
+		dialect:  dialect,
+		logger:   logger,
+	}, nil
+}
+
+// Function to save a new processed receipt with its items and points to the database
+func (receiptRepository *SQLRepository) Save(ctx context.Context, receipt *model.ProcessedReceipt) (model.ProcessedReceipt, error) {
+	logger := receiptRepository.logger
+	logger.Infof("saving processed receipt with id %v to the database", receipt.ID())
+
+	err := receiptRepository.inTransaction(ctx, func(tx *sql.Tx) error {
+		values, err := receiptValues(receipt)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, receiptRepository.dialect.rebind("INSERT INTO receipts ("+receiptColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"), values...); err != nil {
+			return err
+		}
+		return receiptRepository.insertItemsAndPoints(ctx, tx, receipt)
+	})
+	if err != nil {
+		logger.Errorf("failed to save receipt with id %v to the database: %v", receipt.ID(), err)
+		return model.ProcessedReceipt{}, receiptRepository.duplicateReceiptErrorOr(ctx, receipt.Fingerprint(), err)
+	}
+	return *receipt, nil
+}
+
+// Function to update an existing processed receipt in the database, its items and points are replaced
+func (receiptRepository *SQLRepository) Update(ctx context.Context, receipt *model.ProcessedReceipt) (model.ProcessedReceipt, error) {
+	logger := receiptRepository.logger
+	logger.Infof("updating processed receipt with id %v in the database", receipt.ID())
+
+	err := receiptRepository.inTransaction(ctx, func(tx *sql.Tx) error {
+		values, err := receiptValues(receipt)
+		if err != nil {
+			return err
+		}
+		// the id is the first receipt column but goes last in the update for the where clause
+		result, err := tx.ExecContext(ctx, receiptRepository.dialect.rebind(`UPDATE receipts SET retailer = ?, retailer_key = ?, purchase_date = ?, purchase_time = ?, time_zone = ?, total_cents = ?,
+			currency = ?, subtotal_cents = ?, tax_cents = ?, tip_cents = ?, discounts = ?, points = ?, rule_set_version = ?, fingerprint = ?, fraud_review = ?,
+			recalculations = ? WHERE id = ?`), append(values[1:], values[0])...)
+		if err != nil {
+			return err
+		}
+		if updatedRows, err := result.RowsAffected(); err != nil {
+			return err
+		} else if updatedRows == 0 {
+			return fmt.Errorf("receipt with ID %s was %w", receipt.ID(), db.ErrNotFound)
+		}
+		for _, table := range []string{"items", "points"} {
+			if _, err := tx.ExecContext(ctx, receiptRepository.dialect.rebind("DELETE FROM "+table+" WHERE receipt_id = ?"), receipt.ID()); err != nil {
+				return err
+			}
+		}
+		return receiptRepository.insertItemsAndPoints(ctx, tx, receipt)
+	})
+	if err != nil {
+		logger.Errorf("failed to update receipt with id %v in the database: %v", receipt.ID(), err)
+		return model.ProcessedReceipt{}, receiptRepository.duplicateReceiptErrorOr(ctx, receipt.Fingerprint(), err)
+	}
+	return *receipt, nil
+}
+
+// Function to find the processed receipt with a fingerprint in the database, a nil receipt is returned when there is none
+func (receiptRepository *SQLRepository) FindByFingerprint(ctx context.Context, fingerprint string) (*model.ProcessedReceipt, error) {
+	log := receiptRepository.logger
+	log.Infof("fetching receipt with fingerprint %v from the database", fingerprint)
+
+	receipts, err := receiptRepository.findReceipts(ctx, "WHERE fingerprint = ?", fingerprint)
+	if err != nil {
+		log.Errorf("failed to fetch receipt with fingerprint %v from the database: %v", fingerprint, err)
+		return nil, err
+	}
+	if len(receipts) == 0 {
+		return nil, nil
+	}
+	return &receipts[0], nil
+}
+
+// Function to find the processed receipts from a retailer on a purchase date, retailer names are compared ignoring case and extra spaces
+func (receiptRepository *SQLRepository) FindByRetailerAndDate(ctx context.Context, retailerName string, purchaseDate string) ([]model.ProcessedReceipt, error) {
+	log := receiptRepository.logger
+	log.Infof("fetching receipts from retailer %v on %v from the database", retailerName, purchaseDate)
+
+	receipts, err := receiptRepository.findReceipts(ctx, "WHERE retailer_key = ? AND purchase_date = ?", retailerKey(retailerName), purchaseDate)
+	if err != nil {
+		log.Errorf("failed to fetch receipts from retailer %v on %v from the database: %v", retailerName, purchaseDate, err)
+	}
+	return receipts, err
+}
+
+// Function to find the processed receipts from a retailer with a purchase date from the first date up to but not including the last date,
+// sorted by purchase date and then in the order they were saved
+func (receiptRepository *SQLRepository) FindByRetailerBetweenDates(ctx context.Context, retailerName string, fromDate string, beforeDate string) ([]model.ProcessedReceipt, error) {
+	log := receiptRepository.logger
+	log.Infof("fetching receipts from retailer %v from %v before %v from the database", retailerName, fromDate, beforeDate)
+
+	if err := checkDateRange(fromDate, beforeDate); err != nil {
+		return nil, err
+	}
+	receipts, err := receiptRepository.findReceipts(ctx, "WHERE retailer_key = ? AND purchase_date >= ? AND purchase_date < ?", retailerKey(retailerName), fromDate, beforeDate)
+	if err != nil {
+		log.Errorf("failed to fetch receipts from retailer %v from %v before %v from the database: %v", retailerName, fromDate, beforeDate, err)
+		return nil, err
+	}
+	sort.SliceStable(receipts, func(i, j int) bool {
+		return receipts[i].Receipt().PurchaseDate < receipts[j].Receipt().PurchaseDate
+	})
+	return receipts, nil
+}
+
+// Function to find every processed receipt in the database in the order they were saved
+func (receiptRepository *SQLRepository) FindAll(ctx context.Context) ([]model.ProcessedReceipt, error) {
+	log := receiptRepository.logger
+	log.Infof("fetching all receipts from the database")
+
+	receipts, err := receiptRepository.findReceipts(ctx, "")
+	if err != nil {
+		log.Errorf("failed to fetch all receipts from the database: %v", err)
+	}
+	return receipts, err
+}
+
+// Function to find a processed receipt in the database by it's id
+func (receiptRepository *SQLRepository) FindById(ctx context.Context, id uuid.UUID) (model.ProcessedReceipt, error) {
+	log := receiptRepository.logger
+	log.Infof("fetching receipt with id %v from the database", id)
+
+	receipts, err := receiptRepository.findReceipts(ctx, "WHERE id = ?", id.String())
+	if err == nil && len(receipts) == 0 {
+		err = fmt.Errorf("receipt with ID %s was %w", id, db.ErrNotFound)
+	}
+	if err != nil {
+		log.Errorf("failed to fetch receipt with id %v from the database: %v", id, err)
+		return model.ProcessedReceipt{}, err
+	}
+	return receipts[0], nil
+}
+
+// Function to delete a processed receipt with its items and points from the database by it's id
+func (receiptRepository *SQLRepository) DeleteById(ctx context.Context, id uuid.UUID) error {
+	log := receiptRepository.logger
+	log.Infof("deleting receipt with id %v from the database", id)
+
+	result, err := receiptRepository.database.ExecContext(ctx, receiptRepository.dialect.rebind("DELETE FROM receipts WHERE id = ?"), id.String())
+	if err == nil {
+		var deletedRows int64
+		deletedRows, err = result.RowsAffected()
+		if err == nil && deletedRows == 0 {
+			err = fmt.Errorf("receipt with ID %s was %w", id, db.ErrNotFound)
+		}
+	}
+	if err != nil {
+		log.Errorf("failed to delete receipt with id %v from the database: %v", id, err)
+	}
+	return err
+}
+
+// Function to close the database, the repository can't be used afterwards
+func (receiptRepository *SQLRepository) Close() error {
+	return receiptRepository.database.Close()
+}
+
+// Function to run a function in a transaction that is committed when it succeeds and rolled back when it fails
+func (receiptRepository *SQLRepository) inTransaction(ctx context.Context, run func(tx *sql.Tx) error) error {
+	tx, err := receiptRepository.database.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := run(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Function to turn a unique fingerprint violation into a DuplicateReceiptError pointing at the stored receipt, other errors are returned as they are
+func (receiptRepository *SQLRepository) duplicateReceiptErrorOr(ctx context.Context, fingerprint string, err error) error {
+	if !receiptRepository.dialect.isDuplicateFingerprint(err) {
+		return err
+	}
+	originalReceipt, findErr := receiptRepository.FindByFingerprint(ctx, fingerprint)
+	if findErr != nil || originalReceipt == nil {
+		return err
+	}
+	return &DuplicateReceiptError{OriginalID: originalReceipt.ID()}
+}
+
+// Function to find the processed receipts matching a where clause, loading the items and points of all of them with one query each
+func (receiptRepository *SQLRepository) findReceipts(ctx context.Context, where string, args ...interface{}) ([]model.ProcessedReceipt, error) {
+	rows, err := receiptRepository.database.QueryContext(ctx, receiptRepository.dialect.rebind("SELECT "+receiptColumns+" FROM receipts "+where+" ORDER BY "+receiptRepository.dialect.orderBy), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var storedReceipts []*storedReceipt
+	storedReceiptsById := make(map[string]*storedReceipt)
+	for rows.Next() {
+		stored, err := scanStoredReceipt(rows)
+		if err != nil {
+			return nil, err
+		}
+		storedReceipts = append(storedReceipts, stored)
+		storedReceiptsById[stored.id] = stored
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(storedReceipts) == 0 {
+		return []model.ProcessedReceipt{}, nil
+	}
+
+	if err := receiptRepository.loadItems(ctx, storedReceiptsById, where, args...); err != nil {
+		return nil, err
+	}
+	if err := receiptRepository.loadPoints(ctx, storedReceiptsById, where, args...); err != nil {
+		return nil, err
+	}
+
+	receipts := make([]model.ProcessedReceipt, 0, len(storedReceipts))
+	for _, stored := range storedReceipts {
+		receipts = append(receipts, stored.processedReceipt())
+	}
+	return receipts, nil
+}
+
+// Function to add the items of the receipts matching a where clause to the stored receipts, in the order they were on the receipt
+func (receiptRepository *SQLRepository) loadItems(ctx context.Context, storedReceiptsById map[string]*storedReceipt, where string, args ...interface{}) error {
+	rows, err := receiptRepository.database.QueryContext(ctx, receiptRepository.dialect.rebind(`SELECT receipt_id, short_description, price_cents, sku, upc, quantity, unit_price_cents FROM items
+		WHERE receipt_id IN (SELECT id FROM receipts `+where+`) ORDER BY receipt_id, position`), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var receiptId string
+		var priceCents int64
+		var unitPriceCents sql.NullInt64
+		var item model.ReceiptItem
+		if err := rows.Scan(&receiptId, &item.ShortDescription, &priceCents, &item.SKU, &item.UPC, &item.Quantity, &unitPriceCents); err != nil {
+			return err
+		}
+		item.Price = model.NewMoney(priceCents)
+		item.UnitPrice = moneyFromNullableCents(unitPriceCents)
+		if stored, exists := storedReceiptsById[receiptId]; exists {
+			stored.receipt.Items = append(stored.receipt.Items, item)
+		}
+	}
+	return rows.Err()
+}
+
+// Function to add the points breakdown of the receipts matching a where clause to the stored receipts, in the order the rules were applied
+func (receiptRepository *SQLRepository) loadPoints(ctx context.Context, storedReceiptsById map[string]*storedReceipt, where string, args ...interface{}) error {
+	rows, err := receiptRepository.database.QueryContext(ctx, receiptRepository.dialect.rebind(`SELECT receipt_id, rule, points, reason, promotion FROM points
+		WHERE receipt_id IN (SELECT id FROM receipts `+where+`) ORDER BY receipt_id, position`), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var receiptId string
+		var ruleBreakdown model.RuleBreakdown
+		if err := rows.Scan(&receiptId, &ruleBreakdown.Rule, &ruleBreakdown.Points, &ruleBreakdown.Reason, &ruleBreakdown.Promotion); err != nil {
+			return err
+		}
+		if stored, exists := storedReceiptsById[receiptId]; exists {
+			stored.breakdown = append(stored.breakdown, ruleBreakdown)
+		}
+	}
+	return rows.Err()
+}
+
+// Function to insert the items and points breakdown of a processed receipt, keeping their position so they load in the same order
+func (receiptRepository *SQLRepository) insertItemsAndPoints(ctx context.Context, tx *sql.Tx, receipt *model.ProcessedReceipt) error {
+	for position, item := range receipt.Receipt().Items {
+		if _, err := tx.ExecContext(ctx, receiptRepository.dialect.rebind(`INSERT INTO items (receipt_id, position, short_description, price_cents, sku, upc, quantity, unit_price_cents)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`), receipt.ID(), position, item.ShortDescription, item.Price.Cents(), item.SKU, item.UPC, item.Quantity, nullableCents(item.UnitPrice)); err != nil {
+			return err
+		}
+	}
+	for position, ruleBreakdown := range receipt.Breakdown() {
+		if _, err := tx.ExecContext(ctx, receiptRepository.dialect.rebind(`INSERT INTO points (receipt_id, position, rule, points, reason, promotion) VALUES (?, ?, ?, ?, ?, ?)`),
+			receipt.ID(), position, ruleBreakdown.Rule, ruleBreakdown.Points, ruleBreakdown.Reason, ruleBreakdown.Promotion); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// a processed receipt as it is read from the receipts table, before its items and points are added
+type storedReceipt struct {
+	id             string
+	receipt        *model.Receipt
+	points         int
+	ruleSetVersion string
+	fingerprint    string
+	fraudReview    model.FraudReview
+	recalculations []model.Recalculation
+	breakdown      []model.RuleBreakdown
+}
+
+// Function to get the values of the receipt columns of a processed receipt in the order of receiptColumns
+func receiptValues(processedReceipt *model.ProcessedReceipt) ([]interface{}, error) {
+	receipt := processedReceipt.Receipt()
+	discounts, err := json.Marshal(receipt.Discounts)
+	if err != nil {
+		return nil, err
+	}
+	fraudReview, err := json.Marshal(processedReceipt.FraudReview())
+	if err != nil {
+		return nil, err
+	}
+	recalculations, err := json.Marshal(processedReceipt.Recalculations())
+	if err != nil {
+		return nil, err
+	}
+	// receipts without a fingerprint are stored with a null one, so they don't clash in the unique index
+	fingerprint := sql.NullString{String: processedReceipt.Fingerprint(), Valid: processedReceipt.Fingerprint() != ""}
+	return []interface{}{
+		processedReceipt.ID(), receipt.RetailerName, retailerKey(receipt.RetailerName), receipt.PurchaseDate, receipt.PurchaseTime, receipt.TimeZone,
+		receipt.TotalAmount.Cents(), receipt.Currency, nullableCents(receipt.Subtotal), nullableCents(receipt.Tax), nullableCents(receipt.Tip),
+		string(discounts), processedReceipt.Points(), processedReceipt.RuleSetVersion(), fingerprint, string(fraudReview), string(recalculations),
+	}, nil
+}
+
+// Function to read a row of receipt columns in the order of receiptColumns
+func scanStoredReceipt(rows *sql.Rows) (*storedReceipt, error) {
+	stored := &storedReceipt{
+		receipt:   &model.Receipt{Items: []model.ReceiptItem{}},
+		breakdown: []model.RuleBreakdown{},
+	}
+	receipt := stored.receipt
+	var retailerKey string
+	var totalCents int64
+	var subtotalCents, taxCents, tipCents sql.NullInt64
+	var discounts, fraudReview, recalculations string
+	var fingerprint sql.NullString
+	err := rows.Scan(&stored.id, &receipt.RetailerName, &retailerKey, &receipt.PurchaseDate, &receipt.PurchaseTime, &receipt.TimeZone, &totalCents, &receipt.Currency,
+		&subtotalCents, &taxCents, &tipCents, &discounts, &stored.points, &stored.ruleSetVersion, &fingerprint, &fraudReview, &recalculations)
+	if err != nil {
+		return nil, err
+	}
+
+	receipt.TotalAmount = model.NewMoney(totalCents)
+	receipt.Subtotal = moneyFromNullableCents(subtotalCents)
+	receipt.Tax = moneyFromNullableCents(taxCents)
+	receipt.Tip = moneyFromNullableCents(tipCents)
+	stored.fingerprint = fingerprint.String
+	if err := json.Unmarshal([]byte(discounts), &receipt.Discounts); err != nil {
+		return nil, fmt.Errorf("failed to read the discounts of receipt %s: %w", stored.id, err)
+	}
+	if err := json.Unmarshal([]byte(fraudReview), &stored.fraudReview); err != nil {
+		return nil, fmt.Errorf("failed to read the fraud review of receipt %s: %w", stored.id, err)
+	}
+	if err := json.Unmarshal([]byte(recalculations), &stored.recalculations); err != nil {
+		return nil, fmt.Errorf("failed to read the recalculations of receipt %s: %w", stored.id, err)
+	}
+	return stored, nil
+}
+
+// Function to rebuild the processed receipt from its stored columns, items and points
+func (stored *storedReceipt) processedReceipt() model.ProcessedReceipt {
+	processedReceipt := model.NewProcessedReceipt(stored.id, stored.receipt, stored.points, stored.breakdown, stored.ruleSetVersion).
+		WithFingerprint(stored.fingerprint).
+		WithFraudReview(stored.fraudReview)
+	for _, recalculation := range stored.recalculations {
+		processedReceipt = processedReceipt.WithRecalculation(recalculation)
+	}
+	return processedReceipt
+}
+
+// Function to rewrite the ? placeholders of a query into numbered placeholders such as $1, for databases like postgres that use them
+func numberedPlaceholders(query string) string {
+	var rebound strings.Builder
+	placeholder := 0
+	for _, character := range query {
+		if character != '?' {
+			rebound.WriteRune(character)
+			continue
+		}
+		placeholder++
+		fmt.Fprintf(&rebound, "$%d", placeholder)
+	}
+	return rebound.String()
+}
+
+// Function to get the cents of an optional amount for a nullable column
+func nullableCents(amount *model.Money) sql.NullInt64 {
+	if amount == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: amount.Cents(), Valid: true}
+}
+
+// Function to get an optional amount from a nullable cents column, null columns are missing amounts
+func moneyFromNullableCents(cents sql.NullInt64) *model.Money {
+	if !cents.Valid {
+		return nil
+	}
+	amount := model.NewMoney(cents.Int64)
+	return &amount
+}
@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/mattn/go-sqlite3"
+	"github.com/sirupsen/logrus"
+	"strings"
+)
+
+const (
+	SQLiteBackend     = "sqlite"
+	DefaultSQLitePath = "receipts.db"
+)
+
+var sqliteDialect = sqlDialect{
+	rebind: func(query string) string {
+		return query
+	},
+	isDuplicateFingerprint: func(err error) bool {
+		var sqliteError sqlite3.Error
+		return errors.As(err, &sqliteError) && sqliteError.ExtendedCode == sqlite3.ErrConstraintUnique && strings.Contains(sqliteError.Error(), "receipts.fingerprint")
+	},
+	orderBy: "created_at, rowid",
+}
+
+// Function to create a new Processed Receipt Repository that keeps the receipts in a sqlite database file, the schema is migrated to the latest version when it is opened
+func NewSQLiteRepository(path string, logger *logrus.Logger) (*SQLRepository, error) {
+	if path == "" {
+		path = DefaultSQLitePath
+	}
+	database, err := sql.Open("sqlite3", "file:"+path+"?_foreign_keys=on&_busy_timeout=5000&_journal_mode=WAL")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite database %s: %w", path, err)
+	}
+	// sqlite only allows one writer at a time, a single connection avoids busy errors and keeps a :memory: database from being opened once per connection
+	database.SetMaxOpenConns(1)
+
+	receiptRepository, err := newSQLRepository(context.Background(), database, SQLiteBackend, sqliteDialect, logger)
+	if err != nil {
+		database.Close()
+		return nil, fmt.Errorf("failed to open sqlite database %s: %w", path, err)
+	}
+	return receiptRepository, nil
+}
@@ -2,9 +2,17 @@ package routes
 
 import (
 	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"receipt-processor-challenge/internal/receipt/currency"
+	"receipt-processor-challenge/internal/receipt/fraud"
 	"receipt-processor-challenge/internal/receipt/handler"
+	"receipt-processor-challenge/internal/receipt/processor"
 	"receipt-processor-challenge/internal/receipt/repository"
 	"receipt-processor-challenge/internal/receipt/service"
+	"receipt-processor-challenge/internal/receipt/timezone"
+	"receipt-processor-challenge/internal/receipt/validator"
+	"receipt-processor-challenge/pkg/config"
 	"receipt-processor-challenge/pkg/logger"
 	"receipt-processor-challenge/pkg/middleware"
 	"time"
@@ -13,14 +21,150 @@ import (
 // Function to initialize the receipt router
 func InitializeReceiptRouter() *mux.Router {
 	log := logger.GetLogger()
-	receiptRepo := repository.NewRepository(log)
+	loadConfiguredRules(log)
+	loadExchangeRates(log)
+	loadTimeZones(log)
+	loadValidationProfiles(log)
+	receiptRepo, err := repository.NewReceiptRepository(config.LoadStorage(), log)
+	if err != nil {
+		log.Fatalf("Error creating receipt repository: %v", err)
+	}
 	receiptService := service.NewService(receiptRepo, log)
+	configureFraudReview(log, receiptService)
 	receiptHandler := handler.NewHandler(receiptService, log)
-	router := mux.NewRouter().PathPrefix("/receipts").Subrouter()
-	router.Use(middleware.WithRequestContext)
-	router.Use(middleware.WithTimeout(5 * time.Second))
-	router.HandleFunc("/{id}/points", receiptHandler.HandleReceiptFetchById).Methods("GET")
-	router.HandleFunc("/process", receiptHandler.HandleReceiptProcessing).Methods("POST")
+	adminToken := viper.GetString("ADMIN_TOKEN")
+	if adminToken == "" {
+		log.Warn("no admin token configured, the admin endpoints reject every request")
+	}
+	return NewReceiptRouter(receiptHandler, adminToken)
+}
+
+// how long the requests of the receipt endpoints and of the admin endpoints, which work through every stored receipt, may take
+const (
+	RequestTimeout      = 5 * time.Second
+	AdminRequestTimeout = 5 * time.Minute
+)
+
+// Function to create the receipt router, the admin endpoints are on their own subrouter that needs the admin token and has a longer request timeout,
+// as they work through every stored receipt
+func NewReceiptRouter(receiptHandler *handler.Handler, adminToken string) *mux.Router {
+	router := mux.NewRouter()
+
+	adminRouter := router.PathPrefix("/receipts/admin").Subrouter()
+	adminRouter.Use(middleware.WithRequestContext)
+	adminRouter.Use(middleware.RequireBearerToken(adminToken))
+	adminRouter.Use(middleware.WithTimeout(AdminRequestTimeout))
+	adminRouter.HandleFunc("/recalculate", receiptHandler.HandleReceiptRecalculation).Methods("POST")
+
+	receiptRouter := router.PathPrefix("/receipts").Subrouter()
+	receiptRouter.Use(middleware.WithRequestContext)
+	receiptRouter.Use(middleware.WithTimeout(RequestTimeout))
+	receiptRouter.HandleFunc("/{id}/points", receiptHandler.HandleReceiptFetchById).Methods("GET")
+	receiptRouter.HandleFunc("/{id}/breakdown", receiptHandler.HandleReceiptBreakdownFetchById).Methods("GET")
+	receiptRouter.HandleFunc("/process", receiptHandler.HandleReceiptProcessing).Methods("POST")
+	receiptRouter.HandleFunc("/simulate", receiptHandler.HandleReceiptSimulation).Methods("POST")
 
 	return router
 }
+
+// Function to replace the default rules with the rules declared in the configured rule file, if there is one, and reload them whenever the file changes
+func loadConfiguredRules(log *logrus.Logger) {
+	rulesConfigPath := viper.GetString("RULES_CONFIG")
+	if rulesConfigPath == "" {
+		log.Info("no rule config file configured, using the default rules")
+		return
+	}
+
+	ruleSetConfig, err := config.LoadRuleSet(rulesConfigPath)
+	if err != nil {
+		log.Fatalf("Error loading rule config: %v", err)
+	}
+	if err := applyRuleSet(ruleSetConfig); err != nil {
+		log.Fatalf("Error applying rules from %s: %v", rulesConfigPath, err)
+	}
+	log.Infof("loaded rule set version %s from %s", ruleSetConfig.Version, rulesConfigPath)
+
+	config.WatchRuleSet(rulesConfigPath, func(ruleSetConfig *config.RuleSetConfig, err error) {
+		if err != nil {
+			log.Errorf("failed to reload rule config, keeping rule set version %s: %v", processor.DefaultRegistry().Version(), err)
+			return
+		}
+		if err := applyRuleSet(ruleSetConfig); err != nil {
+			log.Errorf("failed to apply reloaded rules, keeping rule set version %s: %v", processor.DefaultRegistry().Version(), err)
+			return
+		}
+		log.Infof("reloaded rule set version %s from %s", ruleSetConfig.Version, rulesConfigPath)
+	})
+}
+
+// Function to build the rules and retailer programs in a rule set config and swap them into the default registry
+func applyRuleSet(ruleSetConfig *config.RuleSetConfig) error {
+	ruleSet, err := processor.BuildRuleSet(ruleSetConfig)
+	if err != nil {
+		return err
+	}
+	return processor.DefaultRegistry().Replace(ruleSet)
+}
+
+// Function to set the exchange rates used to normalize receipts in other currencies from the configured exchange rate file, if there is one
+func loadExchangeRates(log *logrus.Logger) {
+	exchangeRatesPath := viper.GetString("EXCHANGE_RATES")
+	if exchangeRatesPath == "" {
+		log.Infof("no exchange rate file configured, only %s receipts can be processed", currency.DefaultCurrency)
+		return
+	}
+
+	provider, err := currency.LoadStaticProvider(exchangeRatesPath)
+	if err != nil {
+		log.Fatalf("Error loading exchange rates: %v", err)
+	}
+	processor.SetExchangeRateProvider(provider)
+	log.Infof("loaded exchange rates from %s", exchangeRatesPath)
+}
+
+// Function to set the retailer time zones used to find the local purchase time from the configured time zone file, if there is one
+func loadTimeZones(log *logrus.Logger) {
+	timeZonesPath := viper.GetString("TIME_ZONES")
+	if timeZonesPath == "" {
+		log.Infof("no time zone file configured, receipts without a time zone are in %s", timezone.DefaultZone)
+		return
+	}
+
+	resolver, err := timezone.LoadResolver(timeZonesPath)
+	if err != nil {
+		log.Fatalf("Error loading time zones: %v", err)
+	}
+	processor.SetTimeZoneResolver(resolver)
+	log.Infof("loaded time zones from %s", timeZonesPath)
+}
+
+// Function to set the validation profiles and the profile of each api client from the configured validation file, if there is one
+func loadValidationProfiles(log *logrus.Logger) {
+	validationPath := viper.GetString("VALIDATION_PROFILES")
+	if validationPath == "" {
+		log.Infof("no validation file configured, receipts are validated with the %s profile", validator.StrictProfileName)
+		return
+	}
+
+	profiles, err := validator.LoadProfiles(validationPath)
+	if err != nil {
+		log.Fatalf("Error loading validation profiles: %v", err)
+	}
+	validator.SetProfiles(profiles)
+	log.Infof("loaded validation profiles from %s", validationPath)
+}
+
+// Function to set the score at which receipts are flagged for fraud review from the configured threshold, if there is one
+func configureFraudReview(log *logrus.Logger, receiptService *service.Service) {
+	if !viper.IsSet("FRAUD_REVIEW_THRESHOLD") {
+		log.Infof("no fraud review threshold configured, flagging receipts scoring at least %v", fraud.DefaultThreshold)
+		return
+	}
+
+	fraudScorer, err := fraud.NewScorer(viper.GetFloat64("FRAUD_REVIEW_THRESHOLD"))
+	if err != nil {
+		log.Fatalf("Error configuring fraud review: %v", err)
+	}
+	receiptService.SetFraudScorer(fraudScorer)
+	log.Infof("flagging receipts scoring at least %v for fraud review", viper.GetFloat64("FRAUD_REVIEW_THRESHOLD"))
+}
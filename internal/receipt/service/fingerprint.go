@@ -0,0 +1,36 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"receipt-processor-challenge/internal/receipt/currency"
+	"receipt-processor-challenge/internal/receipt/model"
+	"sort"
+	"strings"
+)
+
+// Function to compute a fingerprint of a receipt's contents. The retailer, purchase date, purchase time, total and items are
+// normalized first so that differences in case, spacing or item order don't hide a resubmitted receipt
+func Fingerprint(receipt *model.Receipt) string {
+	items := make([]string, 0, len(receipt.Items))
+	for _, item := range receipt.Items {
+		items = append(items, fmt.Sprintf("%q|%d|%d|%q|%q", normalizeText(item.ShortDescription), item.Price.Cents(), item.Units(), strings.ToUpper(item.SKU), item.UPC))
+	}
+	sort.Strings(items)
+
+	canonical := strings.Join([]string{
+		normalizeText(receipt.RetailerName),
+		receipt.PurchaseDate,
+		receipt.PurchaseTime,
+		fmt.Sprintf("%d %s", receipt.TotalAmount.Cents(), currency.CodeOrDefault(receipt.Currency)),
+		strings.Join(items, "\n"),
+	}, "\n")
+	checksum := sha256.Sum256([]byte(canonical))
+	return hex.EncodeToString(checksum[:])
+}
+
+// Function to lower case text and collapse runs of whitespace into single spaces
+func normalizeText(text string) string {
+	return strings.ToLower(strings.Join(strings.Fields(text), " "))
+}
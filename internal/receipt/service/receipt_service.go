@@ -4,31 +4,63 @@ import (
 	"context"
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
+	"receipt-processor-challenge/internal/receipt/fraud"
 	"receipt-processor-challenge/internal/receipt/model"
 	"receipt-processor-challenge/internal/receipt/processor"
 	"receipt-processor-challenge/internal/receipt/repository"
+	"sort"
+	"time"
 )
 
 type Service struct {
-	repo   *repository.Repository
-	logger *logrus.Logger
+	repo        repository.ReceiptRepository
+	logger      *logrus.Logger
+	fraudScorer *fraud.Scorer
 }
 
 // Function to create a new Receipt Service
-func NewService(repo *repository.Repository, logger *logrus.Logger) *Service {
+func NewService(repo repository.ReceiptRepository, logger *logrus.Logger) *Service {
+	fraudScorer, _ := fraud.NewScorer(fraud.DefaultThreshold)
 	return &Service{
-		repo:   repo,
-		logger: logger,
+		repo:        repo,
+		logger:      logger,
+		fraudScorer: fraudScorer,
 	}
 }
 
-// Function to process a receipt
+// Function to set the scorer that flags processed receipts that are suspiciously similar to recent receipts
+func (receiptService *Service) SetFraudScorer(fraudScorer *fraud.Scorer) {
+	receiptService.fraudScorer = fraudScorer
+}
+
+// Function to process a receipt, receipts with the same fingerprint as a stored receipt are rejected with a repository.DuplicateReceiptError
 func (receiptService *Service) ProcessReceipt(ctx context.Context, receipt *model.Receipt) (*model.ProcessedReceipt, error) {
 	logger := receiptService.logger
 
 	logger.Infoln("Processing receipt")
-	processedReceipt := processor.ProcessReceipt(receipt)
-	savedProcessedReceipt, err := receiptService.saveProcessedReceipt(ctx, processedReceipt)
+	fingerprint := Fingerprint(receipt)
+	originalReceipt, err := receiptService.repo.FindByFingerprint(ctx, fingerprint)
+	if err != nil {
+		logger.Errorf("Error checking for duplicate receipts: %v", err)
+		return &model.ProcessedReceipt{}, err
+	}
+	if originalReceipt != nil {
+		logger.Warnf("Rejecting duplicate of receipt %s", originalReceipt.ID())
+		return &model.ProcessedReceipt{}, &repository.DuplicateReceiptError{OriginalID: originalReceipt.ID()}
+	}
+
+	processedReceipt, err := processor.ProcessReceipt(receipt)
+	if err != nil {
+		logger.Errorf("Error processing receipt: %v", err)
+		return &model.ProcessedReceipt{}, err
+	}
+	fraudReview, err := receiptService.reviewForFraud(ctx, receipt)
+	if err != nil {
+		logger.Errorf("Error reviewing receipt for fraud: %v", err)
+		return &model.ProcessedReceipt{}, err
+	}
+	reviewedReceipt := processedReceipt.WithFingerprint(fingerprint).WithFraudReview(fraudReview)
+	savedProcessedReceipt, err := receiptService.saveProcessedReceipt(ctx, &reviewedReceipt)
 	if err != nil {
 		logger.Errorf("Error saving processed receipt: %v", err)
 		return &model.ProcessedReceipt{}, err
@@ -36,6 +68,19 @@ func (receiptService *Service) ProcessReceipt(ctx context.Context, receipt *mode
 	return &savedProcessedReceipt, nil
 }
 
+// Function to score a receipt without saving it, used to test receipts against the rules
+func (receiptService *Service) SimulateReceipt(ctx context.Context, receipt *model.Receipt) (*model.ProcessedReceipt, error) {
+	logger := receiptService.logger
+
+	logger.Infoln("Simulating receipt")
+	processedReceipt, err := processor.ProcessReceipt(receipt)
+	if err != nil {
+		logger.Errorf("Error simulating receipt: %v", err)
+		return &model.ProcessedReceipt{}, err
+	}
+	return processedReceipt, nil
+}
+
 // Function to find a receipt by it's id
 func (receiptService *Service) FindReceiptById(ctx context.Context, receiptId string) (model.ProcessedReceipt, error) {
 	logger := receiptService.logger
@@ -72,6 +117,108 @@ func (receiptService *Service) DeleteReceiptById(ctx context.Context, receiptId
 	return err
 }
 
+// Function to recalculate the points of every stored receipt under a rule set version, keeping the original points alongside the recalculated points.
+// Every receipt is recalculated before any is written, and when a write fails the receipts already written are put back, so the store is never
+// left half recalculated
+func (receiptService *Service) RecalculateReceipts(ctx context.Context, ruleSetVersion string) ([]model.ProcessedReceipt, error) {
+	logger := receiptService.logger
+	logger.Infof("Calling service to recalculate receipts with rule set version %s", ruleSetVersion)
+
+	ruleSet, err := processor.DefaultRegistry().RuleSet(ruleSetVersion)
+	if err != nil {
+		logger.Errorf("Error finding rule set: %v", err)
+		return nil, err
+	}
+
+	receipts, err := receiptService.repo.FindAll(ctx)
+	if err != nil {
+		logger.Errorf("Error finding receipts: %v", err)
+		return nil, err
+	}
+	sort.Slice(receipts, func(i, j int) bool {
+		return receipts[i].ID() < receipts[j].ID()
+	})
+
+	recalculatedReceipts := make([]model.ProcessedReceipt, 0, len(receipts))
+	for _, receipt := range receipts {
+		if err := ctx.Err(); err != nil {
+			logger.Errorf("Error recalculating receipts, no receipts were changed: %v", err)
+			return nil, err
+		}
+
+		points, breakdown, err := processor.CalculatePoints(ruleSet, receipt.Receipt())
+		if err != nil {
+			logger.Errorf("Error recalculating receipt %s, no receipts were changed: %v", receipt.ID(), err)
+			return nil, err
+		}
+		recalculation := model.NewRecalculation(ruleSet.Version, receipt.Points(), points, breakdown, time.Now())
+		recalculatedReceipts = append(recalculatedReceipts, receipt.WithRecalculation(recalculation))
+	}
+
+	// the writes aren't cancelled with the request, stopping half way would leave some receipts recalculated and others not
+	writeCtx := context.WithoutCancel(ctx)
+	updatedReceipts := make([]model.ProcessedReceipt, 0, len(recalculatedReceipts))
+	for index := range recalculatedReceipts {
+		updatedReceipt, err := receiptService.repo.Update(writeCtx, &recalculatedReceipts[index])
+		if err != nil {
+			logger.Errorf("Error saving recalculated receipt, putting back the %d receipts already saved: %v", index, err)
+			receiptService.restoreReceipts(writeCtx, receipts[:index], updatedReceipts)
+			return nil, err
+		}
+		updatedReceipts = append(updatedReceipts, updatedReceipt)
+	}
+	return updatedReceipts, nil
+}
+
+// Function to put receipts back the way they were before a recalculation that failed part way through. A receipt is only put back while it is
+// still the way the recalculation saved it, so a receipt changed by another request in the meantime keeps that change
+func (receiptService *Service) restoreReceipts(ctx context.Context, originalReceipts []model.ProcessedReceipt, savedReceipts []model.ProcessedReceipt) {
+	logger := receiptService.logger
+	for index := range originalReceipts {
+		receiptId := originalReceipts[index].ID()
+		currentReceipt, err := receiptService.repo.FindById(ctx, uuid.MustParse(receiptId))
+		if err != nil {
+			logger.Errorf("Error finding receipt %s to put it back after a failed recalculation: %v", receiptId, err)
+			continue
+		}
+		if !isUnchangedSince(currentReceipt, savedReceipts[index]) {
+			logger.Warnf("Not putting back receipt %s after a failed recalculation, it was changed by another request", receiptId)
+			continue
+		}
+		if _, err := receiptService.repo.Update(ctx, &originalReceipts[index]); err != nil {
+			logger.Errorf("Error putting back receipt %s after a failed recalculation: %v", receiptId, err)
+		}
+	}
+}
+
+// Function to check a stored receipt has the same recalculations as when it was saved, every change to a stored receipt is a new recalculation
+func isUnchangedSince(currentReceipt model.ProcessedReceipt, savedReceipt model.ProcessedReceipt) bool {
+	if len(currentReceipt.Recalculations()) != len(savedReceipt.Recalculations()) {
+		return false
+	}
+	currentRecalculation, currentExists := currentReceipt.LatestRecalculation()
+	savedRecalculation, savedExists := savedReceipt.LatestRecalculation()
+	return currentExists == savedExists &&
+		currentRecalculation.RuleSetVersion == savedRecalculation.RuleSetVersion &&
+		currentRecalculation.RecalculatedPoints == savedRecalculation.RecalculatedPoints &&
+		currentRecalculation.RecalculatedAt.Equal(savedRecalculation.RecalculatedAt)
+}
+
+// Function to score a receipt against the stored receipts from the same retailer and date, suspicious receipts are flagged rather than rejected
+func (receiptService *Service) reviewForFraud(ctx context.Context, receipt *model.Receipt) (model.FraudReview, error) {
+	logger := receiptService.logger
+
+	recentReceipts, err := receiptService.repo.FindByRetailerAndDate(ctx, receipt.RetailerName, receipt.PurchaseDate)
+	if err != nil {
+		return model.FraudReview{}, err
+	}
+	fraudReview := receiptService.fraudScorer.Review(receipt, recentReceipts)
+	if fraudReview.Flagged {
+		logger.Warnf("Flagging receipt for fraud review with score %.2f, similar to receipt %s", fraudReview.Score, fraudReview.SimilarReceiptID)
+	}
+	return fraudReview, nil
+}
+
 // Function to save a processed receipt to the dataset for persistence
 func (receiptService *Service) saveProcessedReceipt(ctx context.Context, receipt *model.ProcessedReceipt) (model.ProcessedReceipt, error) {
 	logger := receiptService.logger
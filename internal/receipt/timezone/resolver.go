@@ -0,0 +1,101 @@
+package timezone
+
+import (
+	"fmt"
+	"receipt-processor-challenge/internal/receipt/model"
+	"receipt-processor-challenge/pkg/config"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Resolver struct {
+	defaultLocation   *time.Location
+	retailerLocations map[string]*time.Location
+}
+
+var (
+	defaultResolver   = NewResolver(time.UTC, nil)
+	defaultResolverMu sync.RWMutex
+)
+
+// Function to create a new resolver, retailers without a configured zone are in the default location
+func NewResolver(defaultLocation *time.Location, retailerLocations map[string]*time.Location) *Resolver {
+	if defaultLocation == nil {
+		defaultLocation = time.UTC
+	}
+	locations := make(map[string]*time.Location, len(retailerLocations))
+	for retailer, location := range retailerLocations {
+		locations[strings.ToLower(strings.TrimSpace(retailer))] = location
+	}
+	return &Resolver{
+		defaultLocation:   defaultLocation,
+		retailerLocations: locations,
+	}
+}
+
+// Function to create a resolver from a time zone file
+func LoadResolver(path string) (*Resolver, error) {
+	timeZonesConfig, err := config.LoadTimeZones(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defaultLocation, err := ParseZone(timeZonesConfig.Default)
+	if err != nil {
+		return nil, fmt.Errorf("invalid default time zone in %s: %w", path, err)
+	}
+
+	retailerLocations := make(map[string]*time.Location, len(timeZonesConfig.Retailers))
+	for retailer, zone := range timeZonesConfig.Retailers {
+		location, err := ParseZone(zone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid time zone for retailer %s in %s: %w", retailer, path, err)
+		}
+		retailerLocations[retailer] = location
+	}
+	return NewResolver(defaultLocation, retailerLocations), nil
+}
+
+// gets the zone the retailer's stores are in, retailer names are matched ignoring case
+func (resolver *Resolver) RetailerLocation(retailerName string) *time.Location {
+	if location, exists := resolver.retailerLocations[strings.ToLower(strings.TrimSpace(retailerName))]; exists {
+		return location
+	}
+	return resolver.defaultLocation
+}
+
+// gets the purchase time of a receipt in the retailer's local time. The purchase date and time are read in the zone on the receipt,
+// or the retailer's zone when the receipt doesn't have one, wall clock times skipped by a daylight saving transition are moved forward
+func (resolver *Resolver) LocalPurchaseTime(receipt *model.Receipt) (time.Time, error) {
+	retailerLocation := resolver.RetailerLocation(receipt.RetailerName)
+	receiptLocation := retailerLocation
+	if receipt.TimeZone != "" {
+		location, err := ParseZone(receipt.TimeZone)
+		if err != nil {
+			return time.Time{}, err
+		}
+		receiptLocation = location
+	}
+
+	purchasedAt, err := time.ParseInLocation("2006-01-02 15:04", receipt.PurchaseDate+" "+receipt.PurchaseTime, receiptLocation)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("purchase date %s and time %s can't be parsed: %w", receipt.PurchaseDate, receipt.PurchaseTime, err)
+	}
+	return purchasedAt.In(retailerLocation), nil
+}
+
+// Function to set the resolver used to find the local time receipts were purchased at
+func SetDefaultResolver(resolver *Resolver) {
+	defaultResolverMu.Lock()
+	defer defaultResolverMu.Unlock()
+	defaultResolver = resolver
+}
+
+// Function to get the purchase time of a receipt in the retailer's local time using the default resolver
+func LocalPurchaseTime(receipt *model.Receipt) (time.Time, error) {
+	defaultResolverMu.RLock()
+	resolver := defaultResolver
+	defaultResolverMu.RUnlock()
+	return resolver.LocalPurchaseTime(receipt)
+}
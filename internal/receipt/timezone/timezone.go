@@ -0,0 +1,53 @@
+package timezone
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	// embeds the IANA time zone database so zones resolve on hosts without one
+	_ "time/tzdata"
+)
+
+const DefaultZone = "UTC"
+
+var offsetRegex = regexp.MustCompile(`^(?:UTC|GMT)?([+-])(\d{2}):?(\d{2})$`)
+
+// Function to parse a time zone, either an IANA name such as America/Chicago or a UTC offset such as -05:00, +0530 or UTC+02:00
+func ParseZone(zone string) (*time.Location, error) {
+	zone = strings.TrimSpace(zone)
+	if zone == "" || strings.EqualFold(zone, "Z") || strings.EqualFold(zone, DefaultZone) {
+		return time.UTC, nil
+	}
+
+	if matches := offsetRegex.FindStringSubmatch(strings.ToUpper(zone)); matches != nil {
+		hours, _ := strconv.Atoi(matches[2])
+		minutes, _ := strconv.Atoi(matches[3])
+		if hours > 14 || minutes > 59 {
+			return nil, fmt.Errorf("utc offset %s is out of range", zone)
+		}
+		offset := hours*60*60 + minutes*60
+		if matches[1] == "-" {
+			offset = -offset
+		}
+		return time.FixedZone(zone, offset), nil
+	}
+
+	location, err := time.LoadLocation(zone)
+	if err != nil {
+		return nil, fmt.Errorf("time zone %s is not a known IANA zone or utc offset: %w", zone, err)
+	}
+	return location, nil
+}
+
+// Function to check if a string is a time zone that can be parsed
+func IsValidZone(zone string) bool {
+	_, err := ParseZone(zone)
+	return err == nil
+}
+
+// Function to convert a time to a time with the same wall clock in UTC, used to compare local times with times configured without a zone
+func WallClock(localTime time.Time) time.Time {
+	return time.Date(localTime.Year(), localTime.Month(), localTime.Day(), localTime.Hour(), localTime.Minute(), localTime.Second(), localTime.Nanosecond(), time.UTC)
+}
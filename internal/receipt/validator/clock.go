@@ -0,0 +1,28 @@
+package validator
+
+import (
+	"sync"
+	"time"
+)
+
+// gets the current time, replaced in tests to validate purchase dates against a fixed time
+type Clock func() time.Time
+
+var (
+	clock   Clock = time.Now
+	clockMu sync.RWMutex
+)
+
+// Function to set the clock purchase dates are validated against
+func SetClock(newClock Clock) {
+	clockMu.Lock()
+	defer clockMu.Unlock()
+	clock = newClock
+}
+
+// Function to get the current time from the clock
+func now() time.Time {
+	clockMu.RLock()
+	defer clockMu.RUnlock()
+	return clock()
+}
@@ -0,0 +1,250 @@
+package validator
+
+import (
+	"fmt"
+	"receipt-processor-challenge/internal/receipt/model"
+	"receipt-processor-challenge/pkg/config"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	StrictProfileName  = "strict"
+	LenientProfileName = "lenient"
+)
+
+type Profile struct {
+	Name               string
+	retailerRegex      *regexp.Regexp
+	retailerMessage    string
+	descriptionRegex   *regexp.Regexp
+	descriptionMessage string
+	tolerance          model.Money
+	submissionWindow   time.Duration
+}
+
+type Profiles struct {
+	defaultProfile *Profile
+	profiles       map[string]*Profile
+	clients        map[string]*Profile
+}
+
+var (
+	profiles   = mustNewProfiles(StrictProfileName, nil, nil)
+	profilesMu sync.RWMutex
+)
+
+// Function to create the strict profile, only ascii letters, numbers, spaces, dashes and ampersands in retailer names and no ampersands in descriptions
+func StrictProfile() *Profile {
+	return &Profile{
+		Name:               StrictProfileName,
+		retailerRegex:      regexp.MustCompile(`^[\w\s\-&]+$`),
+		retailerMessage:    "retailer may only contain letters, numbers, spaces, dashes and ampersands",
+		descriptionRegex:   regexp.MustCompile(`^[\w\s\-]+$`),
+		descriptionMessage: "shortDescription may only contain letters, numbers, spaces and dashes",
+		tolerance:          model.NewMoney(0),
+	}
+}
+
+// Function to create the lenient profile, which also allows letters in any language, apostrophes and periods such as in Trader Joe's, Café Nero or 12oz.
+func LenientProfile() *Profile {
+	return &Profile{
+		Name:               LenientProfileName,
+		retailerRegex:      regexp.MustCompile(`^[\p{L}\p{M}\p{N}_\s\-&'’.]+$`),
+		retailerMessage:    "retailer may only contain letters, numbers, spaces, dashes, ampersands, apostrophes and periods",
+		descriptionRegex:   regexp.MustCompile(`^[\p{L}\p{M}\p{N}_\s\-'’.]+$`),
+		descriptionMessage: "shortDescription may only contain letters, numbers, spaces, dashes, apostrophes and periods",
+		tolerance:          model.NewMoney(0),
+	}
+}
+
+// Function to create a custom profile from another profile, replacing the retailer and description patterns that are given
+func NewCustomProfile(name string, base *Profile, retailerPattern string, descriptionPattern string) (*Profile, error) {
+	profile := *base
+	profile.Name = name
+	if retailerPattern != "" {
+		retailerRegex, err := regexp.Compile(retailerPattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid retailer pattern for validation profile %s: %w", name, err)
+		}
+		profile.retailerRegex = retailerRegex
+		profile.retailerMessage = fmt.Sprintf("retailer must match the pattern of the %s validation profile", name)
+	}
+	if descriptionPattern != "" {
+		descriptionRegex, err := regexp.Compile(descriptionPattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid description pattern for validation profile %s: %w", name, err)
+		}
+		profile.descriptionRegex = descriptionRegex
+		profile.descriptionMessage = fmt.Sprintf("shortDescription must match the pattern of the %s validation profile", name)
+	}
+	return &profile, nil
+}
+
+// Function to create a copy of the profile that lets the subtotal and total differ from the amounts they add up to by up to a tolerance
+func (profile *Profile) WithTolerance(tolerance model.Money) *Profile {
+	tolerantProfile := *profile
+	tolerantProfile.tolerance = tolerance
+	return &tolerantProfile
+}
+
+// Function to create a copy of the profile that rejects receipts purchased longer ago than the submission window, a window of zero accepts receipts of any age
+func (profile *Profile) WithSubmissionWindow(submissionWindow time.Duration) *Profile {
+	windowedProfile := *profile
+	windowedProfile.submissionWindow = submissionWindow
+	return &windowedProfile
+}
+
+// gets how long after the purchase a receipt can be submitted, zero when receipts of any age are accepted
+func (profile *Profile) SubmissionWindow() time.Duration {
+	return profile.submissionWindow
+}
+
+// gets the largest difference allowed between the subtotal or total and the amounts they add up to
+func (profile *Profile) Tolerance() model.Money {
+	return profile.tolerance
+}
+
+// Function to create the set of validation profiles, the strict and lenient profiles are always included. Clients map api client ids to profile names
+func NewProfiles(defaultName string, customProfiles []*Profile, clients map[string]string) (*Profiles, error) {
+	builtinProfiles := map[string]*Profile{
+		StrictProfileName:  StrictProfile(),
+		LenientProfileName: LenientProfile(),
+	}
+	return newProfiles(defaultName, builtinProfiles, customProfiles, clients)
+}
+
+// Function to create the set of validation profiles from the builtin profiles and the custom profiles
+func newProfiles(defaultName string, builtinProfiles map[string]*Profile, customProfiles []*Profile, clients map[string]string) (*Profiles, error) {
+	validationProfiles := &Profiles{
+		profiles: builtinProfiles,
+		clients:  make(map[string]*Profile, len(clients)),
+	}
+	for _, profile := range customProfiles {
+		if _, exists := validationProfiles.profiles[profile.Name]; exists {
+			return nil, fmt.Errorf("validation profile %s is declared more than once", profile.Name)
+		}
+		validationProfiles.profiles[profile.Name] = profile
+	}
+
+	if defaultName == "" {
+		defaultName = StrictProfileName
+	}
+	defaultProfile, exists := validationProfiles.profiles[defaultName]
+	if !exists {
+		return nil, fmt.Errorf("default validation profile %s does not exist", defaultName)
+	}
+	validationProfiles.defaultProfile = defaultProfile
+
+	for clientId, profileName := range clients {
+		profile, exists := validationProfiles.profiles[profileName]
+		if !exists {
+			return nil, fmt.Errorf("validation profile %s of client %s does not exist", profileName, clientId)
+		}
+		validationProfiles.clients[strings.ToLower(clientId)] = profile
+	}
+	return validationProfiles, nil
+}
+
+// Function to create the validation profiles from a validation file
+func LoadProfiles(path string) (*Profiles, error) {
+	validationConfig, err := config.LoadValidation(path)
+	if err != nil {
+		return nil, err
+	}
+
+	tolerance, err := toleranceOrDefault(validationConfig.Tolerance, model.NewMoney(0))
+	if err != nil {
+		return nil, fmt.Errorf("invalid tolerance in %s: %w", path, err)
+	}
+	submissionWindow, err := submissionWindowOrDefault(&validationConfig.SubmissionWindowDays, 0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid submission window in %s: %w", path, err)
+	}
+	builtinProfiles := map[string]*Profile{
+		StrictProfileName:  StrictProfile().WithTolerance(tolerance).WithSubmissionWindow(submissionWindow),
+		LenientProfileName: LenientProfile().WithTolerance(tolerance).WithSubmissionWindow(submissionWindow),
+	}
+	customProfiles := make([]*Profile, 0, len(validationConfig.Profiles))
+	for _, profileConfig := range validationConfig.Profiles {
+		if profileConfig.Name == "" {
+			return nil, fmt.Errorf("validation profiles in %s must have a name", path)
+		}
+		baseName := profileConfig.Extends
+		if baseName == "" {
+			baseName = StrictProfileName
+		}
+		base, exists := builtinProfiles[baseName]
+		if !exists {
+			return nil, fmt.Errorf("validation profile %s in %s extends %s, which must be %s or %s", profileConfig.Name, path, baseName, StrictProfileName, LenientProfileName)
+		}
+		profile, err := NewCustomProfile(profileConfig.Name, base, profileConfig.RetailerPattern, profileConfig.DescriptionPattern)
+		if err != nil {
+			return nil, err
+		}
+		profileTolerance, err := toleranceOrDefault(profileConfig.Tolerance, base.tolerance)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tolerance for validation profile %s in %s: %w", profileConfig.Name, path, err)
+		}
+		profileSubmissionWindow, err := submissionWindowOrDefault(profileConfig.SubmissionWindowDays, base.submissionWindow)
+		if err != nil {
+			return nil, fmt.Errorf("invalid submission window for validation profile %s in %s: %w", profileConfig.Name, path, err)
+		}
+		customProfiles = append(customProfiles, profile.WithTolerance(profileTolerance).WithSubmissionWindow(profileSubmissionWindow))
+	}
+	return newProfiles(validationConfig.Default, builtinProfiles, customProfiles, validationConfig.Clients)
+}
+
+// Function to parse a tolerance amount formatted like 0.02, falling back to the default when it is missing
+func toleranceOrDefault(value string, defaultTolerance model.Money) (model.Money, error) {
+	if value == "" {
+		return defaultTolerance, nil
+	}
+	tolerance, err := model.ParseMoney(value)
+	if err != nil {
+		return model.Money{}, err
+	}
+	if tolerance.IsNegative() {
+		return model.Money{}, fmt.Errorf("tolerance must not be negative but got %s", value)
+	}
+	return tolerance, nil
+}
+
+// Function to turn a submission window in days into a duration, falling back to the default when it is missing
+func submissionWindowOrDefault(days *int, defaultWindow time.Duration) (time.Duration, error) {
+	if days == nil {
+		return defaultWindow, nil
+	}
+	if *days < 0 {
+		return 0, fmt.Errorf("submission window must not be negative but got %d days", *days)
+	}
+	return time.Duration(*days) * 24 * time.Hour, nil
+}
+
+// Function to set the validation profiles used to validate receipts
+func SetProfiles(validationProfiles *Profiles) {
+	profilesMu.Lock()
+	defer profilesMu.Unlock()
+	profiles = validationProfiles
+}
+
+// Function to get the validation profile of an api client, clients without a configured profile use the default profile
+func ProfileForClient(clientId string) *Profile {
+	profilesMu.RLock()
+	defer profilesMu.RUnlock()
+	if profile, exists := profiles.clients[strings.ToLower(clientId)]; exists {
+		return profile
+	}
+	return profiles.defaultProfile
+}
+
+// Function to create a set of profiles that are known to be valid
+func mustNewProfiles(defaultName string, customProfiles []*Profile, clients map[string]string) *Profiles {
+	validationProfiles, err := NewProfiles(defaultName, customProfiles, clients)
+	if err != nil {
+		panic(err)
+	}
+	return validationProfiles
+}
@@ -1,22 +1,138 @@
 package validator
 
 import (
-	"math"
+	"fmt"
+	"receipt-processor-challenge/internal/receipt/currency"
 	"receipt-processor-challenge/internal/receipt/model"
+	"receipt-processor-challenge/internal/receipt/timezone"
 	"regexp"
-	"strconv"
 	"time"
 )
 
+// how far ahead of the clock a purchase time can be, so receipts from registers with a slightly fast clock aren't rejected
+const futurePurchaseGracePeriod = 5 * time.Minute
+
 // IsValidReceipt Function to check if the receipt is valid according to the business rules
 func IsValidReceipt(receipt model.Receipt) bool {
-	validRetailer := isValidRetailer(receipt.RetailerName)
-	validDate := isValidDate(receipt.PurchaseDate)
-	validTime := isValidTime(receipt.PurchaseTime)
-	validPrice := isValidPrice(receipt.TotalAmount)
-	validItemsList := isValidItemsList(receipt.Items)
-	priceMatchesItemsList := isPriceMatchingItemsListTotal(receipt.TotalAmount, receipt.Items)
-	return validRetailer && validDate && validTime && validPrice && validItemsList && priceMatchesItemsList
+	return len(ValidateReceipt(receipt)) == 0
+}
+
+// ValidateReceipt Function to check the receipt against the business rules with the default profile, returning an error for every field that fails
+func ValidateReceipt(receipt model.Receipt) []model.FieldError {
+	return ValidateReceiptWithProfile(receipt, ProfileForClient(""))
+}
+
+// ValidateReceiptWithProfile Function to check the receipt against the business rules with a validation profile, returning an error for every field that fails
+func ValidateReceiptWithProfile(receipt model.Receipt, profile *Profile) []model.FieldError {
+	fieldErrors := []model.FieldError{}
+	addError := func(code string, message string, path ...interface{}) {
+		fieldErrors = append(fieldErrors, model.NewFieldError(model.FieldPath(path...), code, message))
+	}
+
+	if receipt.RetailerName == "" {
+		addError(model.ValidationErrorRequired, "retailer is required", "retailer")
+	} else if !isValidRetailer(receipt.RetailerName, profile) {
+		addError(model.ValidationErrorInvalidFormat, profile.retailerMessage, "retailer")
+	}
+	if receipt.PurchaseDate == "" {
+		addError(model.ValidationErrorRequired, "purchaseDate is required", "purchaseDate")
+	} else if !isValidDate(receipt.PurchaseDate) {
+		addError(model.ValidationErrorInvalidFormat, "purchaseDate must be a date formatted like 2022-01-31", "purchaseDate")
+	}
+	if receipt.PurchaseTime == "" {
+		addError(model.ValidationErrorRequired, "purchaseTime is required", "purchaseTime")
+	} else if !isValidTime(receipt.PurchaseTime) {
+		addError(model.ValidationErrorInvalidFormat, "purchaseTime must be a 24-hour time formatted like 13:01", "purchaseTime")
+	}
+	if !isValidTimeZone(receipt.TimeZone) {
+		addError(model.ValidationErrorUnknownValue, "timeZone must be an IANA time zone such as America/Chicago or a utc offset such as -05:00", "timeZone")
+	} else if isValidDate(receipt.PurchaseDate) && isValidTime(receipt.PurchaseTime) {
+		addPurchaseAgeErrors(addError, &receipt, profile)
+	}
+	if !isValidCurrency(receipt.Currency) {
+		addError(model.ValidationErrorUnknownValue, "currency must be an upper case ISO-4217 currency code with an exchange rate to "+currency.DefaultCurrency+", such as "+currency.DefaultCurrency, "currency")
+	}
+	addAmountErrors(addError, receipt.TotalAmount, "total")
+
+	if len(receipt.Items) == 0 {
+		addError(model.ValidationErrorRequired, "items must contain at least one item", "items")
+	}
+	for index, item := range receipt.Items {
+		if item.ShortDescription == "" {
+			addError(model.ValidationErrorRequired, "shortDescription is required", "items", index, "shortDescription")
+		} else if !isValidItemDescription(item.ShortDescription, profile) {
+			addError(model.ValidationErrorInvalidFormat, profile.descriptionMessage, "items", index, "shortDescription")
+		}
+		addSignedAmountErrors(addError, item.Price, "items", index, "price")
+		if !isValidSKU(item.SKU) {
+			addError(model.ValidationErrorInvalidFormat, "sku may only contain up to 64 letters, numbers, underscores and dashes", "items", index, "sku")
+		}
+		if !isValidUPC(item.UPC) {
+			addError(model.ValidationErrorInvalidFormat, "upc must be an 8, 12, 13 or 14 digit code", "items", index, "upc")
+		}
+		if item.Quantity < 0 {
+			addError(model.ValidationErrorNegativeAmount, "quantity must not be negative", "items", index, "quantity")
+		} else if item.UnitPrice != nil {
+			addSignedAmountErrors(addError, *item.UnitPrice, "items", index, "unitPrice")
+			if item.UnitPrice.IsValid() && item.Price.IsValid() && !isValidQuantity(item) {
+				addError(model.ValidationErrorTotalMismatch, "unitPrice multiplied by quantity must equal price", "items", index, "price")
+			}
+		}
+	}
+
+	if receipt.Subtotal != nil {
+		addAmountErrors(addError, *receipt.Subtotal, "subtotal")
+	}
+	if receipt.Tax != nil {
+		addAmountErrors(addError, *receipt.Tax, "tax")
+	}
+	for index, discount := range receipt.Discounts {
+		addAmountErrors(addError, discount.Amount, "discounts", index, "amount")
+	}
+	if receipt.Tip != nil {
+		addAmountErrors(addError, *receipt.Tip, "tip")
+	}
+
+	if len(fieldErrors) > 0 {
+		return fieldErrors
+	}
+	if receipt.Subtotal != nil && !isWithinTolerance(*receipt.Subtotal, receipt.ItemsTotal(), profile.tolerance) {
+		addError(model.ValidationErrorTotalMismatch, fmt.Sprintf("subtotal must equal the sum of the item prices %s within %s", receipt.ItemsTotal(), profile.tolerance), "subtotal")
+	} else if !isWithinTolerance(receipt.TotalAmount, receipt.ExpectedTotal(), profile.tolerance) {
+		addError(model.ValidationErrorTotalMismatch, fmt.Sprintf("total must equal the subtotal plus tax and tip minus discounts %s within %s", receipt.ExpectedTotal(), profile.tolerance), "total")
+	}
+	return fieldErrors
+}
+
+// Function to add an error when the receipt was purchased in the future or longer ago than the profile's submission window
+func addPurchaseAgeErrors(addError func(code string, message string, path ...interface{}), receipt *model.Receipt, profile *Profile) {
+	purchasedAt, err := timezone.LocalPurchaseTime(receipt)
+	if err != nil {
+		return
+	}
+	currentTime := now()
+	if purchasedAt.After(currentTime.Add(futurePurchaseGracePeriod)) {
+		addError(model.ValidationErrorFutureDate, "purchaseDate and purchaseTime must not be in the future", "purchaseDate")
+	} else if profile.submissionWindow > 0 && currentTime.Sub(purchasedAt) > profile.submissionWindow {
+		addError(model.ValidationErrorStaleReceipt, fmt.Sprintf("receipts must be submitted within %d days of the purchase", int(profile.submissionWindow.Hours()/24)), "purchaseDate")
+	}
+}
+
+// Function to add the errors of an amount that must be formatted like 0.00 and not be negative
+func addAmountErrors(addError func(code string, message string, path ...interface{}), amount model.Money, path ...interface{}) {
+	field := path[len(path)-1]
+	if !amount.IsValid() {
+		addError(model.ValidationErrorInvalidFormat, fmt.Sprintf("%v must be an amount formatted like 0.00", field), path...)
+	} else if amount.IsNegative() {
+		addError(model.ValidationErrorNegativeAmount, fmt.Sprintf("%v must not be negative", field), path...)
+	}
+}
+
+// Function to add the errors of an amount that must be formatted like 0.00 and may be negative, such as the price of a returned item
+func addSignedAmountErrors(addError func(code string, message string, path ...interface{}), amount model.Money, path ...interface{}) {
+	if !amount.IsValid() {
+		addError(model.ValidationErrorInvalidFormat, fmt.Sprintf("%v must be an amount formatted like 0.00 or -0.00", path[len(path)-1]), path...)
+	}
 }
 
 // Function to check if a string is in a 24-hour time format
@@ -38,85 +154,73 @@ func isValidDate(dateStr string) bool {
 	return err == nil
 }
 
-// Function to check if the retailer name is valid
-func isValidRetailer(retailerName string) bool {
+// Function to check if the retailer name is valid under a validation profile
+func isValidRetailer(retailerName string, profile *Profile) bool {
 	if retailerName == "" {
 		return false
 	}
-	nameRegex := `^[\w\s\-&]+$`
-	re := regexp.MustCompile(nameRegex)
-	return re.MatchString(retailerName)
+	return profile.retailerRegex.MatchString(retailerName)
 }
 
-// Function to check if the price is valid
-func isValidPrice(priceStr string) bool {
-	if priceStr == "" {
-		return false
+// Function to check if the optional sku of an item is valid
+func isValidSKU(sku string) bool {
+	if sku == "" {
+		return true
 	}
-	priceRegex := `^\d+\.\d{2}$`
-	re := regexp.MustCompile(priceRegex)
-	return re.MatchString(priceStr)
+	skuRegex := `^[\w\-]{1,64}$`
+	re := regexp.MustCompile(skuRegex)
+	return re.MatchString(sku)
 }
 
-// Function to check if the items list of the receipt is valid
-func isValidItemsList(receiptItems []model.ReceiptItem) bool {
-	if len(receiptItems) == 0 {
-		return false
-	}
-	for _, item := range receiptItems {
-		descriptionIsValid := isValidItemDescription(item.ShortDescription)
-		priceIsValid := isValidPrice(item.Price)
-		if !descriptionIsValid || !priceIsValid {
-			return false
-		}
+// Function to check if the optional upc of an item is a valid UPC-E, UPC-A, EAN-13 or GTIN-14 code
+func isValidUPC(upc string) bool {
+	if upc == "" {
+		return true
 	}
-	return true
+	upcRegex := `^(\d{8}|\d{12,14})$`
+	re := regexp.MustCompile(upcRegex)
+	return re.MatchString(upc)
 }
 
-// Function to check if the total and items list pricing match up
-func isPriceMatchingItemsListTotal(priceStr string, receiptItems []model.ReceiptItem) bool {
-	if priceStr == "" {
-		return false
-	}
-	if len(receiptItems) == 0 {
-		return false
+// Function to check if the optional quantity and unit price of an item are valid and add up to the item price, returned items have a negative unit price.
+// Negative quantities are reported before this is called
+func isValidQuantity(item model.ReceiptItem) bool {
+	if item.UnitPrice == nil {
+		return true
 	}
-	inputIsValid := isValidPrice(priceStr) && isValidItemsList(receiptItems)
-	if !inputIsValid {
+	if !item.UnitPrice.IsValid() {
 		return false
 	}
+	unitsTotal := model.NewMoney(item.UnitPrice.Cents() * int64(item.Units()))
+	return unitsTotal.Cmp(item.Price) == 0
+}
 
-	itemsListPriceTotal := 0.00
-	reportedPrice, err := strconv.ParseFloat(priceStr, 64)
-	if err != nil {
-		return false
-	}
+// Function to check if an amount matches the amount it is expected to be, allowing a difference of up to the tolerance for rounding
+func isWithinTolerance(amount model.Money, expectedAmount model.Money, tolerance model.Money) bool {
+	return amount.Sub(expectedAmount).Abs().Cmp(tolerance) <= 0
+}
 
-	for _, receiptItem := range receiptItems {
-		itemPriceAsFloat, err := strconv.ParseFloat(receiptItem.Price, 64)
-		if err != nil {
-			return false
-		}
-		itemsListPriceTotal += itemPriceAsFloat
+// Function to check if the currency is a known ISO-4217 currency code that receipts can be converted from with the configured exchange rates,
+// receipts without a currency are in the default currency
+func isValidCurrency(currencyCode string) bool {
+	if currencyCode == "" {
+		return true
 	}
+	return currency.IsSupportedCurrency(currencyCode)
+}
 
-	truncatedItemsListPriceTotal := truncateFloat(itemsListPriceTotal, 2)
-	truncatedReportedPrice := truncateFloat(reportedPrice, 2)
-	return truncatedReportedPrice == truncatedItemsListPriceTotal
+// Function to check if the time zone is an IANA zone or utc offset, receipts without a time zone are in the retailer's zone
+func isValidTimeZone(zone string) bool {
+	if zone == "" {
+		return true
+	}
+	return timezone.IsValidZone(zone)
 }
 
-// Function to check if the item description is valid
-func isValidItemDescription(itemDescription string) bool {
+// Function to check if the item description is valid under a validation profile
+func isValidItemDescription(itemDescription string, profile *Profile) bool {
 	if itemDescription == "" {
 		return false
 	}
-	descriptionRegex := `^[\w\s\-]+$`
-	re := regexp.MustCompile(descriptionRegex)
-	return re.MatchString(itemDescription)
-}
-
-// Function that truncates a float to a given number of decimal points
-func truncateFloat(f float64, decimals int) float64 {
-	power := math.Pow(10, float64(decimals))
-	return math.Floor(f*power) / power
+	return profile.descriptionRegex.MatchString(itemDescription)
 }
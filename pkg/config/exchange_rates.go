@@ -0,0 +1,26 @@
+package config
+
+import (
+	"fmt"
+	"github.com/spf13/viper"
+)
+
+type ExchangeRatesConfig struct {
+	Base  string             `mapstructure:"base"`
+	Rates map[string]float64 `mapstructure:"rates"`
+}
+
+// Function that loads exchange rates from a yaml or json file, the format is picked from the file extension
+func LoadExchangeRates(path string) (*ExchangeRatesConfig, error) {
+	ratesViper := viper.New()
+	ratesViper.SetConfigFile(path)
+	if err := ratesViper.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("error occurred while reading exchange rate file %s: %w", path, err)
+	}
+
+	var exchangeRatesConfig ExchangeRatesConfig
+	if err := ratesViper.Unmarshal(&exchangeRatesConfig); err != nil {
+		return nil, fmt.Errorf("error occurred while decoding exchange rate file %s: %w", path, err)
+	}
+	return &exchangeRatesConfig, nil
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+	"os"
+)
+
+// the rules declared in a rule file, the checksum is derived from the file contents so it only changes when the file does
+type RuleSetConfig struct {
+	Version          string                  `mapstructure:"version"`
+	Rules            []RuleConfig            `mapstructure:"rules"`
+	RetailerPrograms []RetailerProgramConfig `mapstructure:"retailer_programs"`
+	Limits           []PointsLimitConfig     `mapstructure:"limits"`
+	Checksum         string                  `mapstructure:"-"`
+}
+
+type RuleConfig struct {
+	Name        string                 `mapstructure:"name"`
+	Type        string                 `mapstructure:"type"`
+	Description string                 `mapstructure:"description"`
+	Params      map[string]interface{} `mapstructure:"params"`
+	Promotion   *PromotionConfig       `mapstructure:"promotion"`
+}
+
+type PromotionConfig struct {
+	Name  string   `mapstructure:"name"`
+	Start string   `mapstructure:"start"`
+	End   string   `mapstructure:"end"`
+	Days  []string `mapstructure:"days"`
+}
+
+type RetailerProgramConfig struct {
+	Name       string  `mapstructure:"name"`
+	Match      string  `mapstructure:"match"`
+	Retailer   string  `mapstructure:"retailer"`
+	Multiplier float64 `mapstructure:"multiplier"`
+	Bonus      int     `mapstructure:"bonus"`
+	Priority   int     `mapstructure:"priority"`
+}
+
+type PointsLimitConfig struct {
+	Name  string   `mapstructure:"name"`
+	Rules []string `mapstructure:"rules"`
+	Min   *int     `mapstructure:"min"`
+	Max   *int     `mapstructure:"max"`
+}
+
+// Function that loads a rule set from a yaml or json file, the format is picked from the file extension.
+// When the file doesn't declare a version the checksum of the file contents is used as the version
+func LoadRuleSet(path string) (*RuleSetConfig, error) {
+	ruleViper := viper.New()
+	ruleViper.SetConfigFile(path)
+	if err := ruleViper.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("error occurred while reading rule config file %s: %w", path, err)
+	}
+
+	var ruleSetConfig RuleSetConfig
+	if err := ruleViper.Unmarshal(&ruleSetConfig); err != nil {
+		return nil, fmt.Errorf("error occurred while decoding rule config file %s: %w", path, err)
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error occurred while reading rule config file %s: %w", path, err)
+	}
+	checksum := sha256.Sum256(contents)
+	ruleSetConfig.Checksum = hex.EncodeToString(checksum[:])[:12]
+	if ruleSetConfig.Version == "" {
+		ruleSetConfig.Version = ruleSetConfig.Checksum
+	}
+	return &ruleSetConfig, nil
+}
+
+// Function that watches a rule set file and reloads it on every change, passing the result or the load error to onChange
+func WatchRuleSet(path string, onChange func(*RuleSetConfig, error)) {
+	ruleViper := viper.New()
+	ruleViper.SetConfigFile(path)
+	ruleViper.OnConfigChange(func(event fsnotify.Event) {
+		onChange(LoadRuleSet(path))
+	})
+	ruleViper.WatchConfig()
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"github.com/spf13/viper"
+	"strings"
+	"time"
+)
+
+type StorageConfig struct {
+	Backend       string
+	SQLitePath    string
+	LogDir        string
+	SnapshotEvery int
+	Postgres      PostgresConfig
+}
+
+// the connection and connection pool settings of the postgres backend
+type PostgresConfig struct {
+	DSN             string
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+	ConnectTimeout  time.Duration
+}
+
+const (
+	DefaultPostgresMaxOpenConns    = 10
+	DefaultPostgresMaxIdleConns    = 5
+	DefaultPostgresConnMaxLifetime = 30 * time.Minute
+	DefaultPostgresConnMaxIdleTime = 5 * time.Minute
+	DefaultPostgresConnectTimeout  = 5 * time.Second
+)
+
+// Function that reads the storage backend receipts are kept in, and where it keeps them, from the environment variables
+func LoadStorage() *StorageConfig {
+	return &StorageConfig{
+		Backend:       strings.ToLower(viper.GetString("STORAGE_BACKEND")),
+		SQLitePath:    viper.GetString("SQLITE_PATH"),
+		LogDir:        viper.GetString("STORE_LOG_DIR"),
+		SnapshotEvery: viper.GetInt("STORE_SNAPSHOT_EVERY"),
+		Postgres:      LoadPostgres(),
+	}
+}
+
+// Function that reads the postgres connection and connection pool settings from the environment variables, settings that aren't set use the defaults.
+// The durations are formatted like 30m or 1h30m
+func LoadPostgres() PostgresConfig {
+	return PostgresConfig{
+		DSN:             viper.GetString("POSTGRES_DSN"),
+		MaxOpenConns:    intOrDefault("POSTGRES_MAX_OPEN_CONNS", DefaultPostgresMaxOpenConns),
+		MaxIdleConns:    intOrDefault("POSTGRES_MAX_IDLE_CONNS", DefaultPostgresMaxIdleConns),
+		ConnMaxLifetime: durationOrDefault("POSTGRES_CONN_MAX_LIFETIME", DefaultPostgresConnMaxLifetime),
+		ConnMaxIdleTime: durationOrDefault("POSTGRES_CONN_MAX_IDLE_TIME", DefaultPostgresConnMaxIdleTime),
+		ConnectTimeout:  durationOrDefault("POSTGRES_CONNECT_TIMEOUT", DefaultPostgresConnectTimeout),
+	}
+}
+
+// Function to read an int environment variable, using the default when it isn't set
+func intOrDefault(key string, defaultValue int) int {
+	if !viper.IsSet(key) {
+		return defaultValue
+	}
+	return viper.GetInt(key)
+}
+
+// Function to read a duration environment variable, using the default when it isn't set
+func durationOrDefault(key string, defaultValue time.Duration) time.Duration {
+	if !viper.IsSet(key) {
+		return defaultValue
+	}
+	return viper.GetDuration(key)
+}
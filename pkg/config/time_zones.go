@@ -0,0 +1,26 @@
+package config
+
+import (
+	"fmt"
+	"github.com/spf13/viper"
+)
+
+type TimeZonesConfig struct {
+	Default   string            `mapstructure:"default"`
+	Retailers map[string]string `mapstructure:"retailers"`
+}
+
+// Function that loads the time zones of retailers from a yaml or json file, the format is picked from the file extension
+func LoadTimeZones(path string) (*TimeZonesConfig, error) {
+	zonesViper := viper.New()
+	zonesViper.SetConfigFile(path)
+	if err := zonesViper.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("error occurred while reading time zone file %s: %w", path, err)
+	}
+
+	var timeZonesConfig TimeZonesConfig
+	if err := zonesViper.Unmarshal(&timeZonesConfig); err != nil {
+		return nil, fmt.Errorf("error occurred while decoding time zone file %s: %w", path, err)
+	}
+	return &timeZonesConfig, nil
+}
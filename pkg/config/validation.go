@@ -0,0 +1,38 @@
+package config
+
+import (
+	"fmt"
+	"github.com/spf13/viper"
+)
+
+type ValidationConfig struct {
+	Default              string                    `mapstructure:"default"`
+	Tolerance            string                    `mapstructure:"tolerance"`
+	SubmissionWindowDays int                       `mapstructure:"submission_window_days"`
+	Profiles             []ValidationProfileConfig `mapstructure:"profiles"`
+	Clients              map[string]string         `mapstructure:"clients"`
+}
+
+type ValidationProfileConfig struct {
+	Name                 string `mapstructure:"name"`
+	Extends              string `mapstructure:"extends"`
+	RetailerPattern      string `mapstructure:"retailer_pattern"`
+	DescriptionPattern   string `mapstructure:"description_pattern"`
+	Tolerance            string `mapstructure:"tolerance"`
+	SubmissionWindowDays *int   `mapstructure:"submission_window_days"`
+}
+
+// Function that loads the validation profiles and the profile of each api client from a yaml or json file, the format is picked from the file extension
+func LoadValidation(path string) (*ValidationConfig, error) {
+	validationViper := viper.New()
+	validationViper.SetConfigFile(path)
+	if err := validationViper.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("error occurred while reading validation file %s: %w", path, err)
+	}
+
+	var validationConfig ValidationConfig
+	if err := validationViper.Unmarshal(&validationConfig); err != nil {
+		return nil, fmt.Errorf("error occurred while decoding validation file %s: %w", path, err)
+	}
+	return &validationConfig, nil
+}
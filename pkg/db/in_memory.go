@@ -1,6 +1,7 @@
 package db
 
 import (
+	"errors"
 	"fmt"
 	"sync"
 )
@@ -10,15 +11,86 @@ type Entity interface {
 }
 
 type Store[K Entity] struct {
-	data map[string]K
-	mu   sync.RWMutex
+	data          map[string]K
+	uniqueIndexes map[string]*uniqueIndex[K]
+	indexes       map[string]*secondaryIndex[K]
+	log           *writeAheadLog[K]
+	closed        bool
+	mu            sync.RWMutex
 }
 
-// Function that creates a new dataset of your chosen type
+// maps a key computed from each entity to the id of the only entity with that key, entities with an empty key aren't indexed
+type uniqueIndex[K Entity] struct {
+	key func(K) string
+	ids map[string]string
+}
+
+var (
+	ErrDuplicateKey = errors.New("duplicate key")
+	ErrNotFound     = errors.New("not found")
+)
+
+type DuplicateKeyError struct {
+	Index      string
+	Key        string
+	ExistingID string
+}
+
+func (err *DuplicateKeyError) Error() string {
+	return fmt.Sprintf("key %s in index %s already belongs to entity with ID %s", err.Key, err.Index, err.ExistingID)
+}
+
+func (err *DuplicateKeyError) Unwrap() error {
+	return ErrDuplicateKey
+}
+
+// Function that creates a new dataset of your chosen type that is only kept in memory, use OpenStore for a dataset that is also written to disk
 func NewStore[K Entity]() *Store[K] {
 	return &Store[K]{
-		data: make(map[string]K),
+		data:          make(map[string]K),
+		uniqueIndexes: make(map[string]*uniqueIndex[K]),
+		indexes:       make(map[string]*secondaryIndex[K]),
+	}
+}
+
+// adds a unique index on a key computed from each entity, saving or updating an entity whose key belongs to another entity fails with a DuplicateKeyError
+func (store *Store[K]) AddUniqueIndex(name string, key func(K) string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if store.hasIndex(name) {
+		return fmt.Errorf("index %s already exists", name)
+	}
+	index := &uniqueIndex[K]{key: key, ids: make(map[string]string)}
+	for id, entity := range store.data {
+		indexKey := key(entity)
+		if indexKey == "" {
+			continue
+		}
+		if existingId, exists := index.ids[indexKey]; exists {
+			return &DuplicateKeyError{Index: name, Key: indexKey, ExistingID: existingId}
+		}
+		index.ids[indexKey] = id
 	}
+	store.uniqueIndexes[name] = index
+	return nil
+}
+
+// finds an entity in the dataset by its key in a unique index
+func (store *Store[K]) FindByUniqueIndex(name string, key string) (K, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	var empty K
+	index, exists := store.uniqueIndexes[name]
+	if !exists {
+		return empty, fmt.Errorf("index %s does not exist", name)
+	}
+	id, exists := index.ids[key]
+	if !exists {
+		return empty, fmt.Errorf("entity with key %s in index %s was %w", key, name, ErrNotFound)
+	}
+	return store.data[id], nil
 }
 
 // saves a new entity to the dataset
@@ -31,8 +103,18 @@ func (store *Store[K]) Save(entity K) (K, error) {
 		var empty K
 		return empty, fmt.Errorf("entity with ID %s already exists", id)
 	}
+	if err := store.checkUniqueIndexes(entity); err != nil {
+		var empty K
+		return empty, err
+	}
+	if err := store.appendToLog(opSave, id, &entity); err != nil {
+		var empty K
+		return empty, err
+	}
 
 	store.data[id] = entity
+	store.indexEntity(entity)
+	store.snapshotIfDue()
 
 	return entity, nil
 }
@@ -45,7 +127,7 @@ func (store *Store[K]) FindById(id string) (K, error) {
 	entity, exists := store.data[id]
 	if !exists {
 		var empty K
-		return empty, fmt.Errorf("entity with ID %s was not found", id)
+		return empty, fmt.Errorf("entity with ID %s was %w", id, ErrNotFound)
 	}
 
 	return entity, nil
@@ -53,15 +135,30 @@ func (store *Store[K]) FindById(id string) (K, error) {
 
 // updates an entity in the dataset
 func (store *Store[K]) Update(entity K) (K, error) {
-	store.mu.RLock()
-	defer store.mu.RUnlock()
+	store.mu.Lock()
+	defer store.mu.Unlock()
 
-	entity, exists := store.data[entity.ID()]
+	id := entity.ID()
+	existingEntity, exists := store.data[id]
 	if !exists {
 		var empty K
-		return empty, fmt.Errorf("entity with ID %s was not found", entity.ID())
+		return empty, fmt.Errorf("entity with ID %s was %w", id, ErrNotFound)
 	}
-	return store.Save(entity)
+	if err := store.checkUniqueIndexes(entity); err != nil {
+		var empty K
+		return empty, err
+	}
+	if err := store.appendToLog(opUpdate, id, &entity); err != nil {
+		var empty K
+		return empty, err
+	}
+
+	store.unindexEntity(existingEntity)
+	store.data[id] = entity
+	store.indexEntity(entity)
+	store.snapshotIfDue()
+
+	return entity, nil
 }
 
 // deletes an entity from the dataset
@@ -69,11 +166,17 @@ func (store *Store[K]) DeleteById(id string) error {
 	store.mu.Lock()
 	defer store.mu.Unlock()
 
-	if _, exists := store.data[id]; !exists {
-		return fmt.Errorf("entity with ID %s was not found", id)
+	entity, exists := store.data[id]
+	if !exists {
+		return fmt.Errorf("entity with ID %s was %w", id, ErrNotFound)
+	}
+	if err := store.appendToLog(opDelete, id, nil); err != nil {
+		return err
 	}
 
+	store.unindexEntity(entity)
 	delete(store.data, id)
+	store.snapshotIfDue()
 	return nil
 }
 
@@ -89,7 +192,7 @@ func (store *Store[K]) List() []K {
 	return entities
 }
 
-// allows querying the dataset based on a predicate function
+// allows querying the dataset based on a predicate function, this checks every entity so lookups by a key should use an index instead
 func (store *Store[K]) Query(predicate func(K) bool) []K {
 	store.mu.RLock()
 	defer store.mu.RUnlock()
@@ -102,3 +205,48 @@ func (store *Store[K]) Query(predicate func(K) bool) []K {
 	}
 	return result
 }
+
+// checks that none of the entity's unique keys belong to another entity, the caller must hold the lock
+func (store *Store[K]) checkUniqueIndexes(entity K) error {
+	for name, index := range store.uniqueIndexes {
+		indexKey := index.key(entity)
+		if indexKey == "" {
+			continue
+		}
+		if existingId, exists := index.ids[indexKey]; exists && existingId != entity.ID() {
+			return &DuplicateKeyError{Index: name, Key: indexKey, ExistingID: existingId}
+		}
+	}
+	return nil
+}
+
+// adds the entity's keys to every unique and secondary index, the caller must hold the lock
+func (store *Store[K]) indexEntity(entity K) {
+	for _, index := range store.uniqueIndexes {
+		if indexKey := index.key(entity); indexKey != "" {
+			index.ids[indexKey] = entity.ID()
+		}
+	}
+	for _, index := range store.indexes {
+		index.add(entity)
+	}
+}
+
+// removes the entity's keys from every unique and secondary index, the caller must hold the lock
+func (store *Store[K]) unindexEntity(entity K) {
+	for _, index := range store.uniqueIndexes {
+		if indexKey := index.key(entity); indexKey != "" && index.ids[indexKey] == entity.ID() {
+			delete(index.ids, indexKey)
+		}
+	}
+	for _, index := range store.indexes {
+		index.remove(entity)
+	}
+}
+
+// checks if there is a unique or secondary index with a name, the caller must hold the lock
+func (store *Store[K]) hasIndex(name string) bool {
+	_, isUnique := store.uniqueIndexes[name]
+	_, isSecondary := store.indexes[name]
+	return isUnique || isSecondary
+}
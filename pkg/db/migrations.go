@@ -0,0 +1,134 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// a versioned change to a database schema, the statements are run in order in a single transaction
+type Migration struct {
+	Version     int
+	Description string
+	Statements  []string
+}
+
+const createMigrationsTable = `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version INTEGER PRIMARY KEY,
+	applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`
+
+var migrationFileRegex = regexp.MustCompile(`^(\d+)_(\w+)\.sql$`)
+
+// Function that reads the migrations in a directory of sql files named like 0001_create_receipts.sql, the number is the version of the migration
+// and the rest of the name describes it. Each file is a single migration and can hold several statements
+func LoadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the migrations in %s: %w", dir, err)
+	}
+
+	migrations := make([]Migration, 0, len(entries))
+	versions := make(map[int]string)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		matches := migrationFileRegex.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			return nil, fmt.Errorf("migration %s must be named like 0001_description.sql", entry.Name())
+		}
+		version, _ := strconv.Atoi(matches[1])
+		if existingName, exists := versions[version]; exists {
+			return nil, fmt.Errorf("migrations %s and %s have the same version %d", existingName, entry.Name(), version)
+		}
+		versions[version] = entry.Name()
+
+		statements, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+		migrations = append(migrations, Migration{
+			Version:     version,
+			Description: strings.ReplaceAll(matches[2], "_", " "),
+			Statements:  []string{string(statements)},
+		})
+	}
+	return migrations, nil
+}
+
+// Function that applies the migrations a database doesn't have yet in version order, the applied versions are recorded in the schema_migrations table
+func Migrate(ctx context.Context, database *sql.DB, migrations []Migration) ([]Migration, error) {
+	if _, err := database.ExecContext(ctx, createMigrationsTable); err != nil {
+		return nil, fmt.Errorf("failed to create the schema_migrations table: %w", err)
+	}
+	appliedVersions, err := appliedMigrationVersions(ctx, database)
+	if err != nil {
+		return nil, err
+	}
+
+	pendingMigrations := make([]Migration, 0, len(migrations))
+	for _, migration := range migrations {
+		if !appliedVersions[migration.Version] {
+			pendingMigrations = append(pendingMigrations, migration)
+		}
+	}
+	sort.Slice(pendingMigrations, func(i, j int) bool {
+		return pendingMigrations[i].Version < pendingMigrations[j].Version
+	})
+
+	for index, migration := range pendingMigrations {
+		if err := applyMigration(ctx, database, migration); err != nil {
+			return pendingMigrations[:index], err
+		}
+	}
+	return pendingMigrations, nil
+}
+
+// Function to find the versions of the migrations that were already applied to a database
+func appliedMigrationVersions(ctx context.Context, database *sql.DB) (map[int]bool, error) {
+	rows, err := database.QueryContext(ctx, "SELECT version FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	appliedVersions := make(map[int]bool)
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, fmt.Errorf("failed to read the applied migrations: %w", err)
+		}
+		appliedVersions[version] = true
+	}
+	return appliedVersions, rows.Err()
+}
+
+// Function to run the statements of a migration and record its version in one transaction, so a failed migration leaves no partial changes behind
+func applyMigration(ctx context.Context, database *sql.DB, migration Migration) error {
+	tx, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start migration %d: %w", migration.Version, err)
+	}
+	defer tx.Rollback()
+
+	for _, statement := range migration.Statements {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("failed to apply migration %d %s: %w", migration.Version, migration.Description, err)
+		}
+	}
+	// the version is an int so it is safe to format into the statement, which keeps this free of driver specific placeholders
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("INSERT INTO schema_migrations (version) VALUES (%d)", migration.Version)); err != nil {
+		return fmt.Errorf("failed to record migration %d: %w", migration.Version, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration %d: %w", migration.Version, err)
+	}
+	return nil
+}
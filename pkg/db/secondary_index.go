@@ -0,0 +1,141 @@
+package db
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// maps a key computed from each entity to the ids of every entity with that key, entities with an empty key aren't indexed.
+// Ordered indexes also keep their keys sorted so the entities with keys in a range can be found without checking every key
+type secondaryIndex[K Entity] struct {
+	key        func(K) string
+	ids        map[string]map[string]struct{}
+	ordered    bool
+	sortedKeys []string
+}
+
+// the separator between the parts of a compound key, it sorts before every other character so keys sort by their first part and then the next
+const indexKeySeparator = "\x00"
+
+// Function to join the parts of a compound index key, such as a retailer and a purchase date, a range of keys that share their first parts
+// covers the entities with those parts, like every receipt from one retailer in a month
+func IndexKey(parts ...string) string {
+	return strings.Join(parts, indexKeySeparator)
+}
+
+// adds an index on a key computed from each entity, many entities can have the same key
+func (store *Store[K]) AddIndex(name string, key func(K) string) error {
+	return store.addSecondaryIndex(name, key, false)
+}
+
+// adds an index on a key computed from each entity that also keeps its keys sorted, so it can be queried for a range of keys with FindByRange
+func (store *Store[K]) AddOrderedIndex(name string, key func(K) string) error {
+	return store.addSecondaryIndex(name, key, true)
+}
+
+// finds the entities in the dataset with a key in an index, sorted by id
+func (store *Store[K]) FindByIndex(name string, key string) ([]K, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	index, exists := store.indexes[name]
+	if !exists {
+		return nil, fmt.Errorf("index %s does not exist", name)
+	}
+	return store.entitiesWithIds(index.ids[key]), nil
+}
+
+// finds the entities in the dataset with a key in an ordered index from the first key up to but not including the last key, sorted by key and then id.
+// An empty last key finds every entity from the first key onwards
+func (store *Store[K]) FindByRange(name string, fromKey string, toKey string) ([]K, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	index, exists := store.indexes[name]
+	if !exists {
+		return nil, fmt.Errorf("index %s does not exist", name)
+	}
+	if !index.ordered {
+		return nil, fmt.Errorf("index %s is not ordered so it can't be queried for a range", name)
+	}
+
+	entities := make([]K, 0)
+	for position := sort.SearchStrings(index.sortedKeys, fromKey); position < len(index.sortedKeys); position++ {
+		key := index.sortedKeys[position]
+		if toKey != "" && key >= toKey {
+			break
+		}
+		entities = append(entities, store.entitiesWithIds(index.ids[key])...)
+	}
+	return entities, nil
+}
+
+// adds a secondary index and indexes the entities already in the dataset
+func (store *Store[K]) addSecondaryIndex(name string, key func(K) string, ordered bool) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if store.hasIndex(name) {
+		return fmt.Errorf("index %s already exists", name)
+	}
+	index := &secondaryIndex[K]{key: key, ids: make(map[string]map[string]struct{}), ordered: ordered}
+	for _, entity := range store.data {
+		index.add(entity)
+	}
+	store.indexes[name] = index
+	return nil
+}
+
+// gets the entities with a set of ids sorted by id, the caller must hold the lock
+func (store *Store[K]) entitiesWithIds(ids map[string]struct{}) []K {
+	sortedIds := make([]string, 0, len(ids))
+	for id := range ids {
+		sortedIds = append(sortedIds, id)
+	}
+	sort.Strings(sortedIds)
+
+	entities := make([]K, 0, len(sortedIds))
+	for _, id := range sortedIds {
+		entities = append(entities, store.data[id])
+	}
+	return entities
+}
+
+// adds an entity to the ids with its key
+func (index *secondaryIndex[K]) add(entity K) {
+	indexKey := index.key(entity)
+	if indexKey == "" {
+		return
+	}
+	ids, exists := index.ids[indexKey]
+	if !exists {
+		ids = make(map[string]struct{})
+		index.ids[indexKey] = ids
+		if index.ordered {
+			position := sort.SearchStrings(index.sortedKeys, indexKey)
+			index.sortedKeys = slices.Insert(index.sortedKeys, position, indexKey)
+		}
+	}
+	ids[entity.ID()] = struct{}{}
+}
+
+// removes an entity from the ids with its key, dropping the key once no entity has it
+func (index *secondaryIndex[K]) remove(entity K) {
+	indexKey := index.key(entity)
+	ids, exists := index.ids[indexKey]
+	if !exists {
+		return
+	}
+	delete(ids, entity.ID())
+	if len(ids) > 0 {
+		return
+	}
+	delete(index.ids, indexKey)
+	if index.ordered {
+		if position := sort.SearchStrings(index.sortedKeys, indexKey); position < len(index.sortedKeys) && index.sortedKeys[position] == indexKey {
+			index.sortedKeys = slices.Delete(index.sortedKeys, position, position+1)
+		}
+	}
+}
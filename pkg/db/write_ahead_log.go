@@ -0,0 +1,287 @@
+package db
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+const (
+	logFileName      = "store.log"
+	snapshotFileName = "store.snapshot"
+
+	opSave   = "save"
+	opUpdate = "update"
+	opDelete = "delete"
+)
+
+// the serialization hook a durable store uses to write its entities to disk and read them back
+type Codec[K Entity] struct {
+	Marshal   func(entity K) ([]byte, error)
+	Unmarshal func(data []byte) (K, error)
+}
+
+// Function to create a codec that writes entities as json, entities with unexported fields can implement json.Marshaler and json.Unmarshaler
+func JSONCodec[K Entity]() Codec[K] {
+	return Codec[K]{
+		Marshal: func(entity K) ([]byte, error) {
+			return json.Marshal(entity)
+		},
+		Unmarshal: func(data []byte) (K, error) {
+			var entity K
+			err := json.Unmarshal(data, &entity)
+			return entity, err
+		},
+	}
+}
+
+type LogOptions[K Entity] struct {
+	Codec Codec[K]
+	// the number of writes after which the log is compacted into a snapshot, 0 only compacts it when Snapshot is called
+	SnapshotEvery int
+	// whether every write is flushed to disk before it returns, so a write isn't lost if the machine crashes
+	SyncWrites bool
+	// called when compacting the log after a write fails, the write itself is kept in the log and compacting is tried again on the next write
+	OnSnapshotError func(err error)
+}
+
+// one line of the log or snapshot file, the entity is left out of deletes
+type logRecord struct {
+	Op     string `json:"op"`
+	ID     string `json:"id"`
+	Entity []byte `json:"entity,omitempty"`
+}
+
+// appends every write to a store to a log file, which is compacted into a snapshot of the whole store every so often
+type writeAheadLog[K Entity] struct {
+	dir                 string
+	file                *os.File
+	options             LogOptions[K]
+	writesSinceSnapshot int
+}
+
+// Function that opens a dataset that is kept in memory and also written to a log in a directory, the entities in the directory's snapshot
+// and log are loaded into the dataset first, so it has the same entities as when it was last closed
+func OpenStore[K Entity](dir string, options LogOptions[K]) (*Store[K], error) {
+	if options.Codec.Marshal == nil || options.Codec.Unmarshal == nil {
+		return nil, fmt.Errorf("a codec is needed to write entities to the log")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create the store directory %s: %w", dir, err)
+	}
+
+	store := NewStore[K]()
+	applyRecord := func(record logRecord) error {
+		return store.applyRecord(options.Codec, record)
+	}
+	if _, err := readLogRecords(filepath.Join(dir, snapshotFileName), applyRecord); err != nil {
+		return nil, err
+	}
+	logPath := filepath.Join(dir, logFileName)
+	writesSinceSnapshot := 0
+	validSize, err := readLogRecords(logPath, func(record logRecord) error {
+		writesSinceSnapshot++
+		return applyRecord(record)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(logPath, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the store log %s: %w", logPath, err)
+	}
+	// a write that was cut off by a crash was never acknowledged, so it is dropped before new writes are appended after it
+	if err := file.Truncate(validSize); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to drop the incomplete write at the end of the store log %s: %w", logPath, err)
+	}
+	store.log = &writeAheadLog[K]{
+		dir:                 dir,
+		file:                file,
+		options:             options,
+		writesSinceSnapshot: writesSinceSnapshot,
+	}
+	return store, nil
+}
+
+// writes the whole dataset to a new snapshot and empties the log, the log is left as it is when the snapshot fails
+func (store *Store[K]) Snapshot() error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if store.log == nil {
+		return fmt.Errorf("the store isn't written to a log")
+	}
+	return store.snapshot()
+}
+
+// closes the log of a durable store, the store can't be written to afterwards
+func (store *Store[K]) Close() error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if store.log == nil {
+		return nil
+	}
+	err := store.log.file.Close()
+	store.log = nil
+	store.closed = true
+	return err
+}
+
+// appends a write to the log before it is applied to the dataset, the caller must hold the lock
+func (store *Store[K]) appendToLog(op string, id string, entity *K) error {
+	if store.closed {
+		return fmt.Errorf("the store is closed")
+	}
+	if store.log == nil {
+		return nil
+	}
+	record, err := store.log.encodeRecord(op, id, entity)
+	if err != nil {
+		return err
+	}
+	if _, err := store.log.file.Write(record); err != nil {
+		return fmt.Errorf("failed to write %s of entity with ID %s to the store log: %w", op, id, err)
+	}
+	if store.log.options.SyncWrites {
+		if err := store.log.file.Sync(); err != nil {
+			return fmt.Errorf("failed to flush %s of entity with ID %s to the store log: %w", op, id, err)
+		}
+	}
+	store.log.writesSinceSnapshot++
+	return nil
+}
+
+// compacts the log into a snapshot once it has had enough writes, the caller must hold the lock
+func (store *Store[K]) snapshotIfDue() {
+	if store.log == nil || store.log.options.SnapshotEvery <= 0 || store.log.writesSinceSnapshot < store.log.options.SnapshotEvery {
+		return
+	}
+	if err := store.snapshot(); err != nil && store.log.options.OnSnapshotError != nil {
+		store.log.options.OnSnapshotError(err)
+	}
+}
+
+// writes the dataset to a temporary file that replaces the snapshot once it is complete, then empties the log, the caller must hold the lock.
+// If the application stops before the log is emptied its writes are loaded again over the new snapshot, which leaves the same entities
+func (store *Store[K]) snapshot() error {
+	snapshotPath := filepath.Join(store.log.dir, snapshotFileName)
+	temporaryPath := snapshotPath + ".tmp"
+	file, err := os.OpenFile(temporaryPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create the store snapshot: %w", err)
+	}
+	writer := bufio.NewWriter(file)
+	for id, entity := range store.data {
+		record, err := store.log.encodeRecord(opSave, id, &entity)
+		if err == nil {
+			_, err = writer.Write(record)
+		}
+		if err != nil {
+			file.Close()
+			os.Remove(temporaryPath)
+			return fmt.Errorf("failed to write the store snapshot: %w", err)
+		}
+	}
+	if err := errors.Join(writer.Flush(), file.Sync(), file.Close()); err != nil {
+		os.Remove(temporaryPath)
+		return fmt.Errorf("failed to write the store snapshot: %w", err)
+	}
+	if err := os.Rename(temporaryPath, snapshotPath); err != nil {
+		os.Remove(temporaryPath)
+		return fmt.Errorf("failed to replace the store snapshot: %w", err)
+	}
+	syncDir(store.log.dir)
+
+	if err := store.log.file.Truncate(0); err != nil {
+		return fmt.Errorf("failed to empty the store log after the snapshot: %w", err)
+	}
+	if err := store.log.file.Sync(); err != nil {
+		return fmt.Errorf("failed to empty the store log after the snapshot: %w", err)
+	}
+	store.log.writesSinceSnapshot = 0
+	return nil
+}
+
+// applies a write read from the snapshot or log to the dataset, saves and updates replace the entity so a write can be applied more than once
+func (store *Store[K]) applyRecord(codec Codec[K], record logRecord) error {
+	switch record.Op {
+	case opSave, opUpdate:
+		entity, err := codec.Unmarshal(record.Entity)
+		if err != nil {
+			return fmt.Errorf("failed to read entity with ID %s from the store log: %w", record.ID, err)
+		}
+		store.data[record.ID] = entity
+	case opDelete:
+		delete(store.data, record.ID)
+	default:
+		return fmt.Errorf("unknown operation %q for entity with ID %s in the store log", record.Op, record.ID)
+	}
+	return nil
+}
+
+// Function to turn a write into a line of the log
+func (log *writeAheadLog[K]) encodeRecord(op string, id string, entity *K) ([]byte, error) {
+	record := logRecord{Op: op, ID: id}
+	if entity != nil {
+		data, err := log.options.Codec.Marshal(*entity)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode entity with ID %s for the store log: %w", id, err)
+		}
+		record.Entity = data
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	return append(line, '\n'), nil
+}
+
+// Function to read the records of a log or snapshot file in order, returning the size of the complete records.
+// A missing file has no records, and a last line without a newline is a write that was cut off and is skipped
+func readLogRecords(path string, apply func(record logRecord) error) (int64, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var validSize int64
+	for lineNumber := 1; ; lineNumber++ {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			return validSize, nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		var record logRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return 0, fmt.Errorf("line %d of %s is corrupt: %w", lineNumber, path, err)
+		}
+		if err := apply(record); err != nil {
+			return 0, fmt.Errorf("line %d of %s: %w", lineNumber, path, err)
+		}
+		validSize += int64(len(line))
+	}
+}
+
+// Function to flush a directory so a file renamed into it survives a crash, platforms that can't sync directories are skipped
+func syncDir(dir string) {
+	directory, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	directory.Sync()
+	directory.Close()
+}
@@ -2,8 +2,11 @@ package middleware
 
 import (
 	"context"
+	"crypto/subtle"
+	"encoding/json"
 	"github.com/google/uuid"
 	"net/http"
+	"strings"
 	"time"
 )
 
@@ -12,21 +15,35 @@ type ContextKey string
 const (
 	RequestIDKey  ContextKey = "request_id"
 	CorrelationID ContextKey = "correlation_id"
+	ClientID      ContextKey = "client_id"
 )
 
-// Middleware Function that adds a request context to the context. setting up request id and correlation id
+// RFC 9457 problem type of requests without a valid token for a protected route
+const UnauthorizedProblemType = "urn:receipt-processor:problems:unauthorized"
+
+type unauthorizedProblemResponse struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail"`
+}
+
+// Middleware Function that adds a request context to the context. setting up request id, correlation id and the api client id
 func WithRequestContext(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
 		ctx := request.Context()
 
 		requestID := uuid.New().String()
-		ctx = context.WithValue(ctx, "request_id", requestID)
+		ctx = context.WithValue(ctx, RequestIDKey, requestID)
 
 		correlationID := request.Header.Get("X-Correlation-ID")
 		if correlationID == "" {
 			correlationID = uuid.New().String()
 		}
-		ctx = context.WithValue(ctx, "correlation_id", correlationID)
+		ctx = context.WithValue(ctx, CorrelationID, correlationID)
+
+		clientID := request.Header.Get("X-Client-ID")
+		ctx = context.WithValue(ctx, ClientID, clientID)
 
 		request = request.WithContext(ctx)
 
@@ -49,3 +66,26 @@ func WithTimeout(duration time.Duration) func(http.Handler) http.Handler {
 		})
 	}
 }
+
+// middleware that only lets through requests with the token in a bearer Authorization header, other requests get a 401 problem details body.
+// An empty token lets no request through
+func RequireBearerToken(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+			requestToken, hasBearerToken := strings.CutPrefix(request.Header.Get("Authorization"), "Bearer ")
+			if token == "" || !hasBearerToken || subtle.ConstantTimeCompare([]byte(requestToken), []byte(token)) != 1 {
+				responseWriter.Header().Set("WWW-Authenticate", "Bearer")
+				responseWriter.Header().Set("Content-Type", "application/problem+json")
+				responseWriter.WriteHeader(http.StatusUnauthorized)
+				json.NewEncoder(responseWriter).Encode(unauthorizedProblemResponse{
+					Type:   UnauthorizedProblemType,
+					Title:  "The request is unauthorized.",
+					Status: http.StatusUnauthorized,
+					Detail: "a valid bearer token is needed in the Authorization header",
+				})
+				return
+			}
+			next.ServeHTTP(responseWriter, request)
+		})
+	}
+}
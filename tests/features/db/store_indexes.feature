@@ -0,0 +1,66 @@
+Feature: Store Indexes
+  As a developer,
+  I want the indexes of a store to follow every save, update and delete
+  So that looking entities up by a key or a range of keys finds the same entities as checking every one
+
+  Background:
+    Given a store of purchases indexed by retailer and date
+    Given purchase "a" from "Target" on "2022-01-01" is saved
+    Given purchase "b" from "Target" on "2022-01-02" is saved
+    Given purchase "c" from "Target" on "2022-01-02" is saved
+    Given purchase "d" from "Target" on "2022-01-03" is saved
+    Given purchase "e" from "Walmart" on "2022-01-01" is saved
+
+  Scenario: Finding saved entities by their key
+    Then the purchases from "Target" on "2022-01-02" should be "b, c"
+    Then the purchases from "Walmart" on "2022-01-01" should be "e"
+    Then the purchases from "Target" should be "a, b, c, d"
+    Then the purchases from "Costco" on "2022-01-01" should be ""
+
+  Scenario: Moving an updated entity to its new keys
+    When purchase "b" is updated to be from "Walmart" on "2022-01-03"
+    Then the purchases from "Target" on "2022-01-02" should be "c"
+    Then the purchases from "Walmart" on "2022-01-03" should be "b"
+    Then the purchases from "Target" should be "a, c, d"
+    Then the purchases from "Walmart" should be "b, e"
+    Then the purchases from "Walmart" from "2022-01-01" to "2022-01-04" should be "e, b"
+
+  Scenario: Dropping the keys of a deleted entity
+    When purchase "a" is deleted
+    Then the purchases from "Target" on "2022-01-01" should be ""
+    Then the purchases from "Target" from "2022-01-01" to "2022-01-03" should be "b, c"
+    When purchase "b" is deleted
+    When purchase "c" is deleted
+    Then the purchases from "Target" on "2022-01-02" should be ""
+    Then the purchases from "Target" from "2022-01-01" to "2022-01-04" should be "d"
+    Then the purchases from "Target" should be "d"
+
+  Scenario: Leaving the indexes alone when a save fails
+    When purchase "a" from "Costco" on "2022-02-01" is saved again
+    Then the save should fail
+    Then the purchases from "Target" on "2022-01-01" should be "a"
+    Then the purchases from "Costco" should be ""
+
+  Scenario: Including the first key of a range and leaving out the last key
+    Then the purchases from "Target" from "2022-01-01" to "2022-01-03" should be "a, b, c"
+    Then the purchases from "Target" from "2022-01-02" to "2022-01-02" should be ""
+    Then the purchases from "Target" from "2022-01-01T" to "2022-01-03" should be "b, c"
+    Then the purchases from "Target" from "2022-01-03" to "2022-01-01" should be ""
+
+  Scenario: Finding every entity from the first key onwards when the range has no last key
+    Then the purchases from "Target" on "2022-01-02" onwards should be "b, c, d, e"
+
+  Scenario: Indexing the entities already in the store when an index is added
+    When the store is indexed by date
+    When purchase "f" from "Costco" on "2022-01-02" is saved
+    Then the purchases dated from "2022-01-01" to "2022-01-02" should be "a, e"
+    Then the purchases dated from "2022-01-02" to "2022-01-04" should be "b, c, f, d"
+
+  Scenario: Only querying ordered indexes for a range
+    Then querying a range of the retailer index should fail
+
+  Scenario: Reporting purchases that aren't in the store as not found
+    When purchase "a" is deleted
+    Then finding purchase "a" should report it was not found
+    Then updating purchase "a" should report it was not found
+    Then deleting purchase "a" should report it was not found
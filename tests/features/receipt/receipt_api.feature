@@ -0,0 +1,120 @@
+Feature: Receipt API
+  As an api client,
+  I want the receipt endpoints to answer with clear statuses and errors
+  So that I can tell what went wrong with a request
+
+  Background:
+    Given I have a valid receipt with the required information
+
+  Scenario: Recalculating stored receipts with the admin token
+    Given the admin token is "admin-secret"
+    Given I have a receipt with a retailer name "SuperMarket123"
+    When I submit the receipt
+    When I send a recalculation request for rule set version "builtin" with the admin token "admin-secret"
+    Then the response status should be 200
+    Then the recalculated points should be 14 with the original points kept
+
+  Scenario: Rejecting a recalculation request without the admin token
+    Given the admin token is "admin-secret"
+    When I submit the receipt
+    When I send a recalculation request for rule set version "builtin" without an admin token
+    Then the response status should be 401
+    Then the response content type should be "application/problem+json"
+    Then the receipt should not be recalculated
+
+  Scenario: Rejecting a recalculation request with the wrong admin token
+    Given the admin token is "admin-secret"
+    When I submit the receipt
+    When I send a recalculation request for rule set version "builtin" with the admin token "guess"
+    Then the response status should be 401
+    Then the receipt should not be recalculated
+
+  Scenario: Rejecting every recalculation request when no admin token is configured
+    When I submit the receipt
+    When I send a recalculation request for rule set version "builtin" with the admin token ""
+    Then the response status should be 401
+    Then the receipt should not be recalculated
+
+  Scenario: Not recalculating any receipt for an unknown rule set version
+    Given the admin token is "admin-secret"
+    When I submit the receipt
+    When I send a recalculation request for rule set version "missing" with the admin token "admin-secret"
+    Then the response status should be 404
+    Then the receipt should not be recalculated
+
+  Scenario: Rejecting a receipt in a currency without an exchange rate with problem details
+    Given the exchange rate from "CAD" to US dollars is 0.73
+    Given I have a receipt in currency "JPY"
+    When I send the receipt to "/receipts/process"
+    Then the response status should be 400
+    Then the response content type should be "application/problem+json"
+    Then the response should report "unknown_value" at "$.currency"
+    Then no receipts should be stored
+
+  Scenario: Rejecting a receipt that isn't valid JSON with problem details
+    When I send a request to "/receipts/process" with the body:
+      """
+      {"retailer": "Target",
+      """
+    Then the response status should be 400
+    Then the response content type should be "application/problem+json"
+    Then the response should report "malformed_json" at "$"
+    Then no receipts should be stored
+
+  Scenario: Rejecting a receipt with a field of the wrong type with problem details
+    When I send a request to "/receipts/process" with the body:
+      """
+      {"retailer": "Target", "purchaseDate": "2022-01-01", "purchaseTime": "13:01", "items": "Mountain Dew 12PK", "total": "6.49"}
+      """
+    Then the response status should be 400
+    Then the response content type should be "application/problem+json"
+    Then the response should report "invalid_type" at "$.items"
+
+  Scenario: Rejecting an invalid receipt sent for simulation with problem details
+    Given I have a receipt with a retailer name "Target!!"
+    When I send the receipt to "/receipts/simulate"
+    Then the response status should be 400
+    Then the response content type should be "application/problem+json"
+    Then the response should report "invalid_format" at "$.retailer"
+
+  Scenario: Answering with problem details when a valid receipt can't be stored
+    Given the receipts are stored in memory with a write-ahead log
+    When I submit the receipt
+    When the receipt storage is closed
+    Given I have a receipt with a retailer name "Walgreens"
+    When I send the receipt to "/receipts/process"
+    Then the response status should be 500
+    Then the response content type should be "application/problem+json"
+    Then the response problem type should be "urn:receipt-processor:problems:processing-failed"
+
+  Scenario: Rejecting an item with a negative quantity with problem details
+    When I send a request to "/receipts/process" with the body:
+      """
+      {"retailer": "Target", "purchaseDate": "2022-01-01", "purchaseTime": "13:01", "items": [{"shortDescription": "Mountain Dew 12PK", "price": "6.49", "quantity": -1, "unitPrice": "6.49"}], "total": "6.49"}
+      """
+    Then the response status should be 400
+    Then the response should report "negative_amount" at "$.items[0].quantity"
+    Then no receipts should be stored
+
+  Scenario: Rejecting a total too large to count in cents with problem details
+    When I send a request to "/receipts/process" with the body:
+      """
+      {"retailer": "Target", "purchaseDate": "2022-01-01", "purchaseTime": "13:01", "items": [{"shortDescription": "Mountain Dew 12PK", "price": "92233720368547758.08"}], "total": "92233720368547758.08"}
+      """
+    Then the response status should be 400
+    Then the response should report "invalid_format" at "$.total"
+    Then the response should report "invalid_format" at "$.items[0].price"
+    Then no receipts should be stored
+
+  Scenario: Validating a receipt with the validation profile of the api client that sent it
+    Given the "mobile-app" client uses the "lenient" validation profile
+    Given I have a receipt with a retailer name "Café Nero's"
+    When I send the receipt to "/receipts/process" from client "mobile-app"
+    Then the response status should be 200
+
+  Scenario: Validating a receipt from an unknown api client with the default validation profile
+    Given the "mobile-app" client uses the "lenient" validation profile
+    Given I have a receipt with a retailer name "Café Nero's"
+    When I send the receipt to "/receipts/process" from client "kiosk"
+    Then the response status should be 400
+    Then the response should report "invalid_format" at "$.retailer"
@@ -14,24 +14,27 @@ Feature: Receipt Rewards System
   Scenario: Earning points for a round dollar total
     Given I have a receipt with a total of 50.00
     When I submit the receipt
-    Then the total points should be 50
+    Then the breakdown should show rule "round-dollar-total" contributing 50 points
+    Then the total points should be 85
 
   Scenario: Earning points for a total that is a multiple of 0.25
     Given I have a receipt with a total of 30.25
     When I submit the receipt
-    Then the total points should be 25
+    Then the breakdown should show rule "quarter-multiple-total" contributing 25 points
+    Then the total points should be 32
 
   Scenario: Earning points for the number of items in the receipt
     Given I have a receipt with 4 items "Shampoo, Conditioner, Soap, Toothpaste" and a final total of 100
     When I submit the receipt
-    Then the total points should be 10
+    Then the breakdown should show rule "item-pairs" contributing 10 points
+    Then the total points should be 85
 
   Scenario: Earning points based on item description length being a multiple of 3
     Given I have a receipt with an item "butter" priced at 12.99
     When I submit the receipt
     Then the total points should be 3
 
-    Given I have a receipt with an item "Conditioner" priced at 8.99
+    Given I have a receipt with an item "Conditioners" priced at 8.99
     When I submit the receipt
     Then the total points should be 2
 
@@ -51,4 +54,445 @@ Feature: Receipt Rewards System
     Given I have a receipt with a purchase date of "2025-02-05"
     Given I have a receipt with a purchase time of "15:30"
     When I submit the receipt
-    Then the total points should be 121
\ No newline at end of file
+    Then the total points should be 123
+
+  Scenario: Recording each rule's contribution in the points breakdown
+    Given I have a receipt with a retailer name "SuperMarket123"
+    Given I have a receipt with a purchase date of "2025-02-05"
+    When I submit the receipt
+    Then the breakdown should show rule "retailer-name" contributing 14 points
+    Then the breakdown should show rule "odd-purchase-day" contributing 6 points
+    Then the breakdown should show rule "afternoon-purchase-time" contributing 0 points
+
+  Scenario: Recalculating stored receipts under a rule set version
+    Given I have a receipt with a retailer name "SuperMarket123"
+    When I submit the receipt
+    When I recalculate the stored receipts with rule set version "builtin"
+    Then the recalculated points should be 14 with the original points kept
+
+  Scenario Outline: Putting back the receipts of a failed recalculation without undoing another request's changes, <storage>
+    Given the receipts are stored <storage>
+    Given I have a receipt with a retailer name "Target"
+    When I submit the receipt
+    Given I have a receipt with a retailer name "Walgreens"
+    When I submit the receipt
+    Given I have a receipt with a retailer name "Costco"
+    When I submit the receipt
+    Given saving the last recalculated receipt fails after another request recalculates the first one
+    Then recalculating the stored receipts with rule set version "builtin" should fail
+    Then only the receipt the other request recalculated should be recalculated
+
+    Examples:
+      | storage                          |
+      | in memory with a write-ahead log |
+      | in a SQLite database             |
+
+  Scenario: Calculating item description points from exact cents
+    Given I have a receipt with an item "Soy" priced at 35.00
+    When I submit the receipt
+    Then the breakdown should show rule "item-description-length" contributing 7 points
+
+  Scenario: Earning points for a round dollar total after converting the receipt currency
+    Given I have a receipt with a total of 100.00
+    Given I have a receipt in currency "CAD"
+    Given the exchange rate from "CAD" to US dollars is 0.73
+    When I submit the receipt
+    Then the breakdown should show rule "round-dollar-total" contributing 50 points
+    Then the breakdown should show rule "item-description-length" contributing 15 points
+
+  Scenario: Not earning points for a round total that isn't a round dollar amount once converted
+    Given I have a receipt with a total of 101.00
+    Given I have a receipt in currency "CAD"
+    Given the exchange rate from "CAD" to US dollars is 0.73
+    When I submit the receipt
+    Then the breakdown should show rule "round-dollar-total" contributing 0 points
+
+  Scenario: Simulating a receipt scores it without storing it
+    Given I have a receipt with a retailer name "SuperMarket123"
+    When I simulate the receipt
+    Then the breakdown should show rule "retailer-name" contributing 14 points
+    Then no receipts should be stored
+
+  Scenario: Earning promotional points during an active promotion
+    Given a "Presidents Day" promotion awards 100 bonus points from "2025-02-15" to "2025-02-17"
+    Given I have a receipt with a purchase date of "2025-02-16"
+    When I submit the receipt
+    Then the breakdown should show rule "Presidents Day-bonus" contributing 100 points
+    Then the breakdown should show rule "Presidents Day-bonus" applying the "Presidents Day" promotion
+
+  Scenario: Not earning promotional points outside of the promotion
+    Given a "Presidents Day" promotion awards 100 bonus points from "2025-02-15" to "2025-02-17"
+    Given I have a receipt with a purchase date of "2025-02-18"
+    When I submit the receipt
+    Then the breakdown should show rule "Presidents Day-bonus" contributing 0 points
+    Then the breakdown should show rule "Presidents Day-bonus" applying the "" promotion
+
+  Scenario: Earning retailer program points with the most specific matching program applied
+    Given a "t-stores-bonus" retailer program matching "^T" by regex awards 1x points and 100 bonus points
+    Given a "target-double" retailer program matching "target" by case-insensitive awards 2x points and 0 bonus points
+    Given I have a receipt with a retailer name "Target"
+    When I submit the receipt
+    Then the breakdown should show rule "target-double" contributing 6 points
+    Then the breakdown should not show rule "t-stores-bonus"
+
+  Scenario: Earning retailer program bonus points from a regex match
+    Given a "t-stores-bonus" retailer program matching "^T" by regex awards 1x points and 100 bonus points
+    Given I have a receipt with a retailer name "Trader Joes"
+    When I submit the receipt
+    Then the breakdown should show rule "t-stores-bonus" contributing 100 points
+
+  Scenario: Earning product bonus points for every unit of a matching sku
+    Given a "gatorade-bonus" product bonus awards 500 points per unit of sku "GAT-32"
+    Given I have a receipt with 2 units of an item "Gatorade" with sku "GAT-32" priced at 2.25 each
+    Given I have a receipt with 1 units of an item "Pepsi" with sku "PEP-12" priced at 1.25 each
+    When I submit the receipt
+    Then the breakdown should show rule "gatorade-bonus" contributing 1000 points
+
+  Scenario: Capping the points a receipt can earn
+    Given a "receipt-cap" limit caps the points of the receipt at 10 points
+    Given I have a receipt with a retailer name "SuperMarket123"
+    When I submit the receipt
+    Then the breakdown should show rule "receipt-cap" contributing -4 points
+
+  Scenario: Capping the points a category of rules can earn
+    Given a "retailer-name-cap" limit caps the points of rules "retailer-name, odd-purchase-day" at 15 points
+    Given I have a receipt with a retailer name "SuperMarket123"
+    Given I have a receipt with a purchase date of "2025-02-05"
+    When I submit the receipt
+    Then the breakdown should show rule "retailer-name-cap" contributing -5 points
+
+  Scenario: Earning afternoon points in the retailer's local time during daylight saving time
+    Given the retailer "Target" is in the "America/New_York" time zone
+    Given I have a receipt with a retailer name "Target"
+    Given I have a receipt with a purchase date of "2022-07-02"
+    Given I have a receipt with a purchase time of "18:30"
+    Given I have a receipt with a time zone of "UTC"
+    When I submit the receipt
+    Then the breakdown should show rule "afternoon-purchase-time" contributing 10 points
+
+  Scenario: Not earning afternoon points in the retailer's local time during standard time
+    Given the retailer "Target" is in the "America/New_York" time zone
+    Given I have a receipt with a retailer name "Target"
+    Given I have a receipt with a purchase date of "2022-01-04"
+    Given I have a receipt with a purchase time of "18:30"
+    Given I have a receipt with a time zone of "+00:00"
+    When I submit the receipt
+    Then the breakdown should show rule "afternoon-purchase-time" contributing 0 points
+
+  Scenario: Earning odd day points for the local purchase date
+    Given the retailer "Target" is in the "America/New_York" time zone
+    Given I have a receipt with a retailer name "Target"
+    Given I have a receipt with a purchase date of "2022-01-02"
+    Given I have a receipt with a purchase time of "03:00"
+    Given I have a receipt with a time zone of "UTC"
+    When I submit the receipt
+    Then the breakdown should show rule "odd-purchase-day" contributing 6 points
+
+  Scenario: Earning afternoon points in the retailer's local time with the rules from the rule file
+    Given the rules are loaded from the rule file "rules.yml"
+    Given the retailer "Target" is in the "America/Chicago" time zone
+    Given I have a receipt with a retailer name "Target"
+    Given I have a receipt with a purchase date of "2022-07-02"
+    Given I have a receipt with a purchase time of "20:30"
+    Given I have a receipt with a time zone of "UTC"
+    When I submit the receipt
+    Then the breakdown should show rule "afternoon-purchase-time" contributing 10 points
+
+  Scenario: Not earning odd day points for the local purchase date with the rules from the rule file
+    Given the rules are loaded from the rule file "rules.yml"
+    Given the retailer "Target" is in the "America/Chicago" time zone
+    Given I have a receipt with a retailer name "Target"
+    Given I have a receipt with a purchase date of "2022-01-03"
+    Given I have a receipt with a purchase time of "03:00"
+    Given I have a receipt with a time zone of "UTC"
+    When I submit the receipt
+    Then the breakdown should show rule "odd-purchase-day" contributing 0 points
+
+  Scenario: Recording the version of the reloaded rules on each receipt
+    Given the rules are loaded from a yaml rule file:
+      """
+      rules:
+        - name: round-dollar-total
+          type: round-dollar
+          params:
+            points: 50
+      """
+    Given the rule file is watched for changes
+    Given I have a receipt with a total of 50.00
+    When I submit the receipt
+    Then the breakdown should show rule "round-dollar-total" contributing 50 points
+    Then the receipt should record the version of the rule file
+
+    When the rule file is changed to:
+      """
+      rules:
+        - name: round-dollar-total
+          type: round-dollar
+          params:
+            points: 75
+      """
+    Given I have a receipt with a retailer name "Target"
+    When I submit the receipt
+    Then the breakdown should show rule "round-dollar-total" contributing 75 points
+    Then the receipt should record the version of the rule file
+    Then the rule set the first receipt was scored with should still be kept
+
+  Scenario: Rejecting a reloaded rule file that reuses its version for different rules
+    Given the rules are loaded from a yaml rule file:
+      """
+      version: v1
+      rules:
+        - name: round-dollar-total
+          type: round-dollar
+          params:
+            points: 50
+      """
+    Given the rule file is watched for changes
+    When the rule file is changed to:
+      """
+      version: v1
+      rules:
+        - name: round-dollar-total
+          type: round-dollar
+          params:
+            points: 75
+      """
+    Then the rule file change should be rejected
+    Given I have a receipt with a total of 50.00
+    When I submit the receipt
+    Then the breakdown should show rule "round-dollar-total" contributing 50 points
+
+  Scenario: Reporting every field that fails validation
+    Given I have a receipt with a retailer name "Tar@get"
+    Given I have a receipt with a purchase time of "25:00"
+    When I validate the receipt
+    Then the validation should report 2 errors
+    Then the validation should report "invalid_format" at "$.retailer"
+    Then the validation should report "invalid_format" at "$.purchaseTime"
+
+  Scenario: Reporting a total that doesn't match the items
+    Given I have a receipt with 3 items "Gatorade,Pepsi,Water" and a final total of 10
+    When I validate the receipt
+    Then the validation should report "total_mismatch" at "$.total"
+
+  Scenario: Rejecting a currency without an exchange rate
+    Given the exchange rate from "CAD" to US dollars is 0.73
+    Given I have a receipt in currency "JPY"
+    When I validate the receipt
+    Then the validation should report "unknown_value" at "$.currency"
+
+  Scenario: Accepting a currency with an exchange rate
+    Given the exchange rate from "CAD" to US dollars is 0.73
+    Given I have a receipt in currency "CAD"
+    When I validate the receipt
+    Then the validation should report 0 errors
+
+  Scenario: Rejecting punctuation in retailer names with the strict validation profile
+    Given I have a receipt with a retailer name "Trader Joe's"
+    When I validate the receipt
+    Then the validation should report "invalid_format" at "$.retailer"
+
+  Scenario: Accepting real world retailer names with the lenient validation profile
+    Given the "mobile-app" client uses the "lenient" validation profile
+    Given I have a receipt with a retailer name "Café Nero's"
+    When I validate the receipt from client "mobile-app"
+    Then the validation should report 0 errors
+
+  Scenario: Reconciling the total with tax, discount and tip lines
+    Given I have a receipt with an item "Gatorade" priced at 10
+    Given I have a receipt with a subtotal of "10.00", tax of "0.80", a discount of "2.00" and a tip of "1.50"
+    Given I have a receipt with a final total of "10.30"
+    When I validate the receipt
+    Then the validation should report 0 errors
+
+  Scenario: Reporting a subtotal that doesn't match the items
+    Given I have a receipt with an item "Gatorade" priced at 10
+    Given I have a receipt with a subtotal of "9.00", tax of "0.80", a discount of "2.00" and a tip of "1.50"
+    Given I have a receipt with a final total of "9.30"
+    When I validate the receipt
+    Then the validation should report "total_mismatch" at "$.subtotal"
+
+  Scenario: Accepting a total that is off by a rounding difference within the tolerance
+    Given the "pos-system" client allows a total tolerance of "0.02"
+    Given I have a receipt with an item "Gatorade" priced at 10
+    Given I have a receipt with a subtotal of "10.00", tax of "0.83", a discount of "0.00" and a tip of "0.00"
+    Given I have a receipt with a final total of "10.84"
+    When I validate the receipt from client "pos-system"
+    Then the validation should report 0 errors
+
+  Scenario: Reporting a total that is off by more than the tolerance
+    Given the "pos-system" client allows a total tolerance of "0.02"
+    Given I have a receipt with an item "Gatorade" priced at 10
+    Given I have a receipt with a subtotal of "10.00", tax of "0.83", a discount of "0.00" and a tip of "0.00"
+    Given I have a receipt with a final total of "10.90"
+    When I validate the receipt from client "pos-system"
+    Then the validation should report "total_mismatch" at "$.total"
+
+  Scenario Outline: Rejecting validation profiles with a negative tolerance
+    Then loading the validation profiles should fail with "<error>":
+      """
+      default: strict
+      tolerance: "<tolerance>"
+      profiles:
+        - name: pos-system
+          extends: strict
+          tolerance: "<profile tolerance>"
+      """
+
+    Examples:
+      | tolerance | profile tolerance | error                                               |
+      | -0.05     | 0.02              | invalid tolerance in                                |
+      | 0.00      | -0.01             | invalid tolerance for validation profile pos-system |
+      | 0.00      | -0.01             | tolerance must not be negative but got -0.01        |
+
+  Scenario: Returned items take away the item points their purchase earned
+    Given I have a receipt with an item "Cheese" priced at 10
+    Given I have a receipt with a returned item "Cheese" priced at -10.00
+    When I validate the receipt
+    When I submit the receipt
+    Then the validation should report 0 errors
+    Then the breakdown should show rule "item-description-length" contributing 0 points
+    Then the breakdown should show rule "item-pairs" contributing 0 points
+
+  Scenario: Coupons lower the total without earning item points
+    Given I have a receipt with 2 items "Cheese,Bread" and a final total of 10
+    Given I have a receipt with a returned item "Coupon Off" priced at -1.00
+    When I submit the receipt
+    Then the breakdown should show rule "item-description-length" contributing 1 points
+    Then the breakdown should show rule "item-pairs" contributing 0 points
+
+  Scenario: Rejecting returns that make the total negative
+    Given I have a receipt with an item "Cheese" priced at 10
+    Given I have a receipt with a returned item "Cheese" priced at -15.00
+    When I validate the receipt
+    Then the validation should report "negative_amount" at "$.total"
+
+  Scenario: Rejecting a receipt that was already processed
+    Given I have a receipt with 2 items "Cheese,Bread" and a final total of 10
+    When I submit the receipt
+    Given I have a receipt with a retailer name "  _ "
+    When I submit the receipt again
+    Then the receipt should be rejected as a duplicate of the first receipt
+
+  Scenario: Accepting a receipt from the same retailer with a different purchase time
+    When I submit the receipt
+    Given I have a receipt with a purchase time of "13:34"
+    When I submit the receipt again
+    Then the receipt should be accepted as a new receipt
+
+  Scenario: Flagging a receipt with a tweaked time and reordered items for fraud review
+    Given I have a receipt with 2 items "Cheese,Bread" and a final total of 10
+    When I submit the receipt
+    Given I have a receipt with 2 items "Bread,Cheese" and a final total of 10
+    Given I have a receipt with a purchase time of "13:41"
+    When I submit the receipt again
+    Then the receipt should be accepted as a new receipt
+    Then the receipt should be flagged for fraud review
+
+  Scenario: Not flagging a different receipt from the same retailer on the same day
+    Given I have a receipt with 2 items "Cheese,Bread" and a final total of 10
+    When I submit the receipt
+    Given I have a receipt with 3 items "Milk,Eggs,Butter" and a final total of 30
+    Given I have a receipt with a purchase time of "18:05"
+    When I submit the receipt again
+    Then the receipt should not be flagged for fraud review
+
+  Scenario: Rejecting receipts purchased in the future
+    Given the current time is "2022-01-01 12:00"
+    When I validate the receipt
+    Then the validation should report "future_date" at "$.purchaseDate"
+
+  Scenario: Rejecting receipts submitted after the submission window
+    Given the current time is "2022-03-01 12:00"
+    Given the "mobile-app" client has a submission window of 30 days
+    When I validate the receipt from client "mobile-app"
+    Then the validation should report "stale_receipt" at "$.purchaseDate"
+
+  Scenario: Accepting receipts submitted within the submission window
+    Given the current time is "2022-01-10 12:00"
+    Given the "mobile-app" client has a submission window of 30 days
+    When I validate the receipt from client "mobile-app"
+    Then the validation should report 0 errors
+
+  Scenario: Keeping receipts stored in SQLite when the application restarts
+    Given the receipts are stored in a SQLite database
+    Given I have a receipt with 2 items "Cheese,Bread" and a final total of 10
+    When I submit the receipt
+    When the application is restarted
+    Then the receipt should still have its points and breakdown
+
+  Scenario: Rejecting a receipt stored in SQLite that is resubmitted after a restart
+    Given the receipts are stored in a SQLite database
+    When I submit the receipt
+    When the application is restarted
+    When I submit the receipt again
+    Then the receipt should be rejected as a duplicate of the first receipt
+
+  Scenario: Keeping receipts written to the write-ahead log when the application restarts
+    Given the receipts are stored in memory with a write-ahead log
+    Given I have a receipt with 2 items "Cheese,Bread" and a final total of 10
+    When I submit the receipt
+    When the application is restarted
+    Then the receipt should still have its points and breakdown
+
+  Scenario: Rejecting a resubmitted receipt after the write-ahead log was compacted into a snapshot
+    Given the receipts are stored in memory with a write-ahead log
+    Given the receipt log is snapshotted after every 1 write
+    When I submit the receipt
+    When the application is restarted
+    When I submit the receipt again
+    Then the receipt should be rejected as a duplicate of the first receipt
+
+  @postgres
+  Scenario: Keeping receipts stored in PostgreSQL when the application restarts
+    Given the receipts are stored in a PostgreSQL database
+    Given I have a receipt with 2 items "Cheese,Bread" and a final total of 10
+    When I submit the receipt
+    When the application is restarted
+    Then the receipt should still have its points and breakdown
+
+  @postgres
+  Scenario: Rejecting a receipt stored in PostgreSQL that is resubmitted after a restart
+    Given the receipts are stored in a PostgreSQL database
+    When I submit the receipt
+    When the application is restarted
+    When I submit the receipt again
+    Then the receipt should be rejected as a duplicate of the first receipt
+
+  @postgres
+  Scenario: Flagging a receipt stored in PostgreSQL for fraud review
+    Given the receipts are stored in a PostgreSQL database
+    Given I have a receipt with 2 items "Cheese,Bread" and a final total of 10
+    When I submit the receipt
+    Given I have a receipt with a purchase time of "13:48"
+    When I submit the receipt again
+    Then the receipt should be flagged for fraud review
+
+  Scenario Outline: Finding the receipts from a retailer over a range of purchase dates in <storage>
+    Given the receipts are stored in <storage>
+    Given I have a receipt with a retailer name "Target"
+    Given I have a receipt with a purchase date of "2022-01-03"
+    When I submit the receipt
+    Given I have a receipt with a purchase date of "2022-01-01"
+    When I submit the receipt
+    Given I have a receipt with a purchase date of "2022-01-02"
+    When I submit the receipt
+    Given I have a receipt with a purchase date of "2022-01-04"
+    When I submit the receipt
+    Given I have a receipt with a retailer name "Walmart"
+    Given I have a receipt with a purchase date of "2022-01-02"
+    When I submit the receipt
+    Then the receipts from "  TARGET " from "2022-01-01" before "2022-01-04" should have the purchase dates "2022-01-01, 2022-01-02, 2022-01-03"
+    Then the receipts from "Target" from "2022-01-02" before "2022-01-03" should have the purchase dates "2022-01-02"
+    Then the receipts from "Target" from "2022-01-05" before "2022-02-01" should have the purchase dates ""
+    Then the receipts from "Walmart" from "2022-01-01" before "2022-02-01" should have the purchase dates "2022-01-02"
+
+    Examples:
+      | storage                           |
+      | memory with a write-ahead log     |
+      | a SQLite database                 |
+
+    @postgres
+    Examples:
+      | storage                |
+      | a PostgreSQL database  |
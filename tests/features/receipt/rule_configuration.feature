@@ -0,0 +1,121 @@
+Feature: Rule Configuration
+  As an operator,
+  I want to declare and tune the points rules in a rule file
+  So that I can change how receipts are scored without changing the code
+
+  Background:
+    Given I have a valid receipt with the required information
+
+  Scenario: Scoring a receipt with the rules from a yaml rule file
+    Given the rules are loaded from a yaml rule file:
+      """
+      rules:
+        - name: retailer-name
+          type: retailer-alphanumeric
+        - name: round-dollar-total
+          type: round-dollar
+        - name: item-pairs
+          type: item-pairs
+        - name: odd-purchase-day
+          type: odd-day
+      """
+    Given I have a receipt with a retailer name "Target"
+    Given I have a receipt with 2 items "Cheese,Bread" and a final total of 10
+    Given I have a receipt with a purchase date of "2022-01-01"
+    When I submit the receipt
+    Then the breakdown should show rule "retailer-name" contributing 6 points
+    Then the breakdown should show rule "round-dollar-total" contributing 50 points
+    Then the breakdown should show rule "item-pairs" contributing 5 points
+    Then the breakdown should show rule "odd-purchase-day" contributing 6 points
+    Then the breakdown should not show rule "afternoon-purchase-time"
+    Then the receipt should record the version of the rule file
+
+  Scenario: Scoring a receipt with the rules from a json rule file
+    Given the rules are loaded from a json rule file:
+      """
+      {
+        "version": "json-rules",
+        "rules": [
+          {"name": "retailer-name", "type": "retailer-alphanumeric", "params": {"points_per_character": 2}},
+          {"name": "round-dollar-total", "type": "round-dollar"}
+        ]
+      }
+      """
+    Given I have a receipt with a retailer name "Target"
+    Given I have a receipt with a total of 10
+    When I submit the receipt
+    Then the breakdown should show rule "retailer-name" contributing 12 points
+    Then the breakdown should show rule "round-dollar-total" contributing 50 points
+    Then the receipt should record the version of the rule file
+
+  Scenario Outline: Scoring a receipt with tuned rule params at <purchase time>
+    Given the rules are loaded from a yaml rule file:
+      """
+      rules:
+        - name: round-dollar-total
+          type: round-dollar
+          params:
+            points: 75
+        - name: afternoon-purchase-time
+          type: purchase-time-window
+          params:
+            points: 20
+            start: "15:00"
+            end: "17:00"
+      """
+    Given I have a receipt with a total of 50
+    Given I have a receipt with a purchase time of "<purchase time>"
+    When I submit the receipt
+    Then the breakdown should show rule "round-dollar-total" contributing 75 points
+    Then the breakdown should show rule "afternoon-purchase-time" contributing <time points> points
+
+    Examples:
+      | purchase time | time points |
+      | 14:30         | 0           |
+      | 15:00         | 20          |
+      | 16:59         | 20          |
+      | 17:00         | 0           |
+
+  Scenario: Rejecting a rule file with an unknown rule type
+    Then loading a yaml rule file should fail with "rule type bonus-points is not supported":
+      """
+      rules:
+        - name: bonus
+          type: bonus-points
+      """
+
+  Scenario Outline: Rejecting a rule file with bad params for a <type> rule
+    Then loading a yaml rule file should fail with "<error>":
+      """
+      rules:
+        - name: bad-rule
+          type: <type>
+          params:
+            <param>: <value>
+      """
+
+    Examples:
+      | type                 | param      | value | error                                          |
+      | round-dollar         | points     | lots  | parameter points must be an integer            |
+      | quarter-multiple     | multiple   | 0     | multiple must be at least 0.01                 |
+      | item-pairs           | group_size | 0     | group_size must be positive                    |
+      | purchase-time-window | start      | 25:00 | parameter start must be a time in the format   |
+      | purchase-time-window | end        | 13:00 | start 14:00 must be before end 13:00           |
+
+  Scenario: Rejecting a rule file with a limit on a rule it doesn't declare
+    Then loading a yaml rule file should fail with "points limit promotions-cap names rule weekend-bonus, which is not in the rule set":
+      """
+      rules:
+        - name: round-dollar-total
+          type: round-dollar
+      limits:
+        - name: promotions-cap
+          rules: [round-dollar-total, weekend-bonus]
+          max: 500
+      """
+
+  Scenario: Rejecting a rule file that isn't valid yaml
+    Then loading a yaml rule file should fail with "error occurred while reading rule config file":
+      """
+      rules: [
+      """
@@ -0,0 +1,38 @@
+Feature: Rule Registry
+  As an operator,
+  I want to add, remove and reorder the points rules
+  So that the rules a receipt is scored with run in the order I choose
+
+  Background:
+    Given a rule registry with the default rules
+
+  Scenario: Scoring a receipt with the rules in the order they were registered
+    Then the breakdown should list the rules "retailer-name, round-dollar-total, quarter-multiple-total, item-pairs, item-description-length, odd-purchase-day, afternoon-purchase-time"
+
+  Scenario: Registering a rule adds it after the other rules
+    When a "launch-bonus" rule awarding 100 points is registered
+    Then the breakdown should list the rules "retailer-name, round-dollar-total, quarter-multiple-total, item-pairs, item-description-length, odd-purchase-day, afternoon-purchase-time, launch-bonus"
+    Then the registered rules should award 100 points
+
+  Scenario: Rejecting a rule registered under a name that is already used
+    Then registering a "retailer-name" rule awarding 100 points should fail
+
+  Scenario: Removing a rule
+    When rule "item-pairs" is removed
+    Then the breakdown should list the rules "retailer-name, round-dollar-total, quarter-multiple-total, item-description-length, odd-purchase-day, afternoon-purchase-time"
+    Then removing rule "item-pairs" should fail
+
+  Scenario: Reordering the rules
+    When a "launch-bonus" rule awarding 100 points is registered
+    When the rules are reordered to "launch-bonus, afternoon-purchase-time, odd-purchase-day, item-description-length, item-pairs, quarter-multiple-total, round-dollar-total, retailer-name"
+    Then the breakdown should list the rules "launch-bonus, afternoon-purchase-time, odd-purchase-day, item-description-length, item-pairs, quarter-multiple-total, round-dollar-total, retailer-name"
+
+  Scenario Outline: Rejecting a reorder that doesn't name every rule once
+    Then reordering the rules to "<names>" should fail
+    Then the breakdown should list the rules "retailer-name, round-dollar-total, quarter-multiple-total, item-pairs, item-description-length, odd-purchase-day, afternoon-purchase-time"
+
+    Examples:
+      | names                                                                                                                               |
+      | retailer-name, round-dollar-total                                                                                                   |
+      | retailer-name, retailer-name, quarter-multiple-total, item-pairs, item-description-length, odd-purchase-day, afternoon-purchase-time |
+      | retailer-name, round-dollar-total, quarter-multiple-total, item-pairs, item-description-length, odd-purchase-day, launch-bonus       |
@@ -0,0 +1,22 @@
+Feature: Storage Backends
+  As an operator,
+  I want to choose where receipts are stored with the storage settings
+  So that I can run the application without a database or with the one I have
+
+  Scenario Outline: Choosing the storage backend "<backend>" from the storage settings
+    Given the storage backend setting is "<backend>"
+    Given the receipt log directory setting is "<log dir>"
+    When the receipt repository is created from the storage settings
+    Then the receipts should be kept <kept>
+
+    Examples:
+      | backend | log dir | kept                             |
+      |         |         | in memory                        |
+      | memory  |         | in memory                        |
+      | memory  | log     | in memory with a write-ahead log |
+      | sqlite  |         | in a SQLite database             |
+      | SQLite  |         | in a SQLite database             |
+
+  Scenario: Rejecting an unknown storage backend
+    Given the storage backend setting is "mongodb"
+    Then creating the receipt repository from the storage settings should fail with "storage backend mongodb is not supported"
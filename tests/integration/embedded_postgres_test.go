@@ -0,0 +1,53 @@
+package integration
+
+import (
+	"bytes"
+	"fmt"
+	embeddedpostgres "github.com/fergusstrange/embedded-postgres"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Function to make sure POSTGRES_TEST_DSN points at a PostgreSQL database for the postgres scenarios, starting an embedded PostgreSQL server when it
+// isn't set. The server's binaries are downloaded on the first run and kept in ~/.embedded-postgres-go, and the server is stopped after the tests
+func usePostgresTestDatabase(t *testing.T) error {
+	if os.Getenv("POSTGRES_TEST_DSN") != "" {
+		return nil
+	}
+
+	port, err := freePort()
+	if err != nil {
+		return err
+	}
+	runtimeDir := t.TempDir()
+	var serverLog bytes.Buffer
+	postgresConfig := embeddedpostgres.DefaultConfig().
+		Port(port).
+		RuntimePath(filepath.Join(runtimeDir, "runtime")).
+		DataPath(filepath.Join(runtimeDir, "data")).
+		Logger(&serverLog)
+	server := embeddedpostgres.NewDatabase(postgresConfig)
+	if err := server.Start(); err != nil {
+		return fmt.Errorf("failed to start an embedded PostgreSQL server, set POSTGRES_TEST_DSN to use another database: %w\n%s", err, serverLog.String())
+	}
+	t.Cleanup(func() {
+		if err := server.Stop(); err != nil {
+			t.Errorf("failed to stop the embedded PostgreSQL server: %v", err)
+		}
+	})
+
+	t.Setenv("POSTGRES_TEST_DSN", postgresConfig.GetConnectionURL()+"?sslmode=disable")
+	return nil
+}
+
+// Function to find a port nothing is listening on
+func freePort() (uint32, error) {
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		return 0, err
+	}
+	defer listener.Close()
+	return uint32(listener.Addr().(*net.TCPAddr).Port), nil
+}
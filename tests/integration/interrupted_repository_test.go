@@ -0,0 +1,87 @@
+package integration
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"receipt-processor-challenge/internal/receipt/model"
+	"receipt-processor-challenge/internal/receipt/repository"
+	"receipt-processor-challenge/internal/receipt/service"
+	"receipt-processor-challenge/pkg/logger"
+	"time"
+)
+
+// the rule set version the recalculation of another request is recorded under
+const otherRequestRuleSetVersion = "other-request"
+
+// a receipt repository whose update of a receipt fails, and that has another request recalculate the first receipt it updated just before
+type interruptedRepository struct {
+	repository.ReceiptRepository
+	updates        int
+	failOnUpdate   int
+	firstUpdatedId string
+}
+
+func (receiptRepository *interruptedRepository) Update(ctx context.Context, receipt *model.ProcessedReceipt) (model.ProcessedReceipt, error) {
+	receiptRepository.updates++
+	if receiptRepository.updates == 1 {
+		receiptRepository.firstUpdatedId = receipt.ID()
+	}
+	if receiptRepository.updates != receiptRepository.failOnUpdate {
+		return receiptRepository.ReceiptRepository.Update(ctx, receipt)
+	}
+
+	firstUpdated, err := receiptRepository.FindById(ctx, uuid.MustParse(receiptRepository.firstUpdatedId))
+	if err != nil {
+		return model.ProcessedReceipt{}, err
+	}
+	recalculation := model.NewRecalculation(otherRequestRuleSetVersion, firstUpdated.Points(), firstUpdated.Points(), firstUpdated.Breakdown(), time.Now())
+	recalculatedByOtherRequest := firstUpdated.WithRecalculation(recalculation)
+	if _, err := receiptRepository.ReceiptRepository.Update(ctx, &recalculatedByOtherRequest); err != nil {
+		return model.ProcessedReceipt{}, err
+	}
+	return model.ProcessedReceipt{}, fmt.Errorf("failed to update receipt %s", receipt.ID())
+}
+
+// "Given" function that will make saving the last recalculated receipt fail, after another request recalculated the first receipt that was saved
+func (t *ReceiptRewardsTest) savingTheLastRecalculatedReceiptFailsAfterAnotherRequestRecalculatesTheFirstOne() error {
+	receipts, err := t.receiptRepo.FindAll(context.Background())
+	if err != nil {
+		return err
+	}
+	if len(receipts) < 3 {
+		return fmt.Errorf("expected at least 3 stored receipts so one is put back but got %d", len(receipts))
+	}
+	t.interruptedRepo = &interruptedRepository{ReceiptRepository: t.receiptRepo, failOnUpdate: len(receipts)}
+	t.receiptService = service.NewService(t.interruptedRepo, logger.GetLogger())
+	return nil
+}
+
+// "When" function that will recalculate the stored receipts under a rule set version and check the recalculation fails
+func (t *ReceiptRewardsTest) recalculatingTheStoredReceiptsWithRuleSetVersionShouldFail(ruleSetVersion string) error {
+	if _, err := t.receiptService.RecalculateReceipts(context.Background(), ruleSetVersion); err == nil {
+		return fmt.Errorf("expected recalculating the stored receipts to fail but it succeeded")
+	}
+	return nil
+}
+
+// "Then" function that will check the receipts were put back, except the one another request recalculated, which keeps that recalculation
+func (t *ReceiptRewardsTest) onlyTheReceiptTheOtherRequestRecalculatedShouldBeRecalculated() error {
+	receipts, err := t.receiptRepo.FindAll(context.Background())
+	if err != nil {
+		return err
+	}
+	for _, receipt := range receipts {
+		recalculation, recalculated := receipt.LatestRecalculation()
+		if receipt.ID() != t.interruptedRepo.firstUpdatedId {
+			if recalculated {
+				return fmt.Errorf("expected receipt %s to be put back but it has %d recalculations", receipt.ID(), len(receipt.Recalculations()))
+			}
+			continue
+		}
+		if !recalculated || recalculation.RuleSetVersion != otherRequestRuleSetVersion {
+			return fmt.Errorf("expected receipt %s to keep the recalculation of the other request but got %v", receipt.ID(), receipt.Recalculations())
+		}
+	}
+	return nil
+}
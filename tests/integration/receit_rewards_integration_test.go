@@ -2,36 +2,78 @@ package integration
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/cucumber/godog"
+	"github.com/google/uuid"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"receipt-processor-challenge/internal/receipt/currency"
+	"receipt-processor-challenge/internal/receipt/handler"
 	"receipt-processor-challenge/internal/receipt/model"
+	"receipt-processor-challenge/internal/receipt/processor"
 	"receipt-processor-challenge/internal/receipt/repository"
+	"receipt-processor-challenge/internal/receipt/routes"
 	"receipt-processor-challenge/internal/receipt/service"
+	"receipt-processor-challenge/internal/receipt/timezone"
+	"receipt-processor-challenge/internal/receipt/validator"
+	"receipt-processor-challenge/pkg/config"
 	"receipt-processor-challenge/pkg/logger"
+	"reflect"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 )
 
 type ReceiptRewardsTest struct {
-	receipt      model.Receipt
-	pointsEarned int64
-	baseUrl      string
+	receipt          model.Receipt
+	receiptId        string
+	pointsEarned     int64
+	breakdown        []model.RuleBreakdown
+	validationErrors []model.FieldError
+	submitErr        error
+	resubmitted      *model.ProcessedReceipt
+	receiptService   *service.Service
+	receiptRepo      repository.ReceiptRepository
+	storageConfig    *config.StorageConfig
+	storageDir       string
+	postgresAdmin    *sql.DB
+	postgresSchema   string
+	addedRules       []string
+	addedPrograms    []string
+	addedLimits      []string
+	replacedRuleSet  *processor.RuleSet
+	ruleFilePath     string
+	ruleReloads      chan error
+	ruleReloadErr    error
+	ruleSetVersions  []string
+	adminToken       string
+	response         *httptest.ResponseRecorder
+	interruptedRepo  *interruptedRepository
+	baseUrl          string
 }
 
 // "Given" function that will create a receipt that will score 0 points
 func (t *ReceiptRewardsTest) iHaveAValidReceipt() error {
 	receiptItems := []model.ReceiptItem{}
 	receiptItems = append(receiptItems, model.ReceiptItem{
-		"item",
-		"0.01",
+		ShortDescription: "item",
+		Price:            model.MustParseMoney("0.01"),
 	})
 	t.receipt = model.Receipt{
-		"_",
-		"2022-01-02",
-		"13:33",
-		"0.01",
-		receiptItems,
+		RetailerName: "_",
+		PurchaseDate: "2022-01-02",
+		PurchaseTime: "13:33",
+		TotalAmount:  model.MustParseMoney("0.01"),
+		Items:        receiptItems,
 	}
 	return nil
 }
@@ -60,45 +102,385 @@ func (t *ReceiptRewardsTest) iHaveAReceiptWithAPurchaseTime(timeStr string) erro
 	return nil
 }
 
+// "Given" function that will set the currency on the receipt (Can be used with other given statements)
+func (t *ReceiptRewardsTest) iHaveAReceiptInCurrency(currencyCode string) error {
+	t.receipt.Currency = currencyCode
+	return nil
+}
+
+// "Given" function that will set the time zone the purchase date and time on the receipt are in (Can be used with other given statements)
+func (t *ReceiptRewardsTest) iHaveAReceiptWithATimeZoneOf(zone string) error {
+	t.receipt.TimeZone = zone
+	return nil
+}
+
+// "Given" function that will set the time zone of a retailer's stores, the time zones are reset after the scenario
+func (t *ReceiptRewardsTest) theRetailerIsInTheTimeZone(retailerName string, zone string) error {
+	location, err := timezone.ParseZone(zone)
+	if err != nil {
+		return err
+	}
+	processor.SetTimeZoneResolver(timezone.NewResolver(time.UTC, map[string]*time.Location{retailerName: location}))
+	return nil
+}
+
+// "Given" function that will set the exchange rate used to normalize receipts in a currency to US dollars
+func (t *ReceiptRewardsTest) theExchangeRateToUSDollarsIs(currencyCode string, rateStr string) error {
+	rateAsFloat, err := strconv.ParseFloat(rateStr, 64)
+	if err != nil {
+		return err
+	}
+	rate, err := currency.NewRate(rateAsFloat)
+	if err != nil {
+		return err
+	}
+	processor.SetExchangeRateProvider(currency.NewStaticProvider(currency.DefaultCurrency, map[string]currency.Rate{currencyCode: rate}))
+	return nil
+}
+
+// "Given" function that will register a promotional rule awarding bonus points between two dates, the rule is removed after the scenario
+func (t *ReceiptRewardsTest) aPromotionAwardsBonusPointsFromTo(promotionName string, points int, start string, end string) error {
+	startDate, err := time.Parse("2006-01-02", start)
+	if err != nil {
+		return err
+	}
+	endDate, err := time.Parse("2006-01-02", end)
+	if err != nil {
+		return err
+	}
+
+	ruleName := promotionName + "-bonus"
+	bonusRule := processor.NewRule(ruleName, "Promotional bonus points.", func(receipt *model.Receipt) processor.RuleResult {
+		return processor.RuleResult{Points: points, Reason: "promotional bonus points"}
+	})
+	promotion := processor.Promotion{Name: promotionName, Start: startDate, End: endDate.AddDate(0, 0, 1)}
+	if err := processor.DefaultRegistry().Register(processor.NewPromotionalRule(bonusRule, promotion)); err != nil {
+		return err
+	}
+	t.addedRules = append(t.addedRules, ruleName)
+	return nil
+}
+
+// "Given" function that will register a retailer program, the program is removed after the scenario
+func (t *ReceiptRewardsTest) aRetailerProgramMatchingAwardsPointsAndBonusPoints(programName string, retailer string, match string, multiplierStr string, bonus int) error {
+	multiplier, err := strconv.ParseFloat(multiplierStr, 64)
+	if err != nil {
+		return err
+	}
+	program, err := processor.NewRetailerProgram(programName, processor.RetailerMatch(match), retailer, multiplier, bonus, 0)
+	if err != nil {
+		return err
+	}
+	if err := processor.DefaultRegistry().RegisterRetailerProgram(program); err != nil {
+		return err
+	}
+	t.addedPrograms = append(t.addedPrograms, programName)
+	return nil
+}
+
+// "Given" function that will register a product bonus rule for a sku, the rule is removed after the scenario
+func (t *ReceiptRewardsTest) aProductBonusAwardsPointsPerUnitOfSKU(ruleName string, points int, sku string) error {
+	match := processor.ProductMatch{SKUs: []string{sku}}
+	if err := processor.DefaultRegistry().Register(processor.NewProductBonusRule(ruleName, "Product bonus points.", match, points)); err != nil {
+		return err
+	}
+	t.addedRules = append(t.addedRules, ruleName)
+	return nil
+}
+
+// "Given" function that will add an item with a sku and quantity to the receipt and raise the total to match (Can be used with other given statements)
+func (t *ReceiptRewardsTest) iHaveAReceiptWithUnitsOfAnItemWithSKUPricedAt(quantity int, item string, sku string, unitPriceStr string) error {
+	unitPrice, err := parseAmount(unitPriceStr)
+	if err != nil {
+		return err
+	}
+	price := model.NewMoney(unitPrice.Cents() * int64(quantity))
+	t.receipt.Items = append(t.receipt.Items, model.ReceiptItem{
+		ShortDescription: item,
+		Price:            price,
+		SKU:              sku,
+		Quantity:         quantity,
+		UnitPrice:        &unitPrice,
+	})
+	t.receipt.TotalAmount = t.receipt.TotalAmount.Add(price)
+	return nil
+}
+
+// "Given" function that will add a returned item or coupon with a negative price to the receipt and lower the total to match (Can be used with other given statements)
+func (t *ReceiptRewardsTest) iHaveAReceiptWithAReturnedItemPricedAt(item string, priceStr string) error {
+	price, err := model.ParseMoney(priceStr)
+	if err != nil {
+		return err
+	}
+	t.receipt.Items = append(t.receipt.Items, model.ReceiptItem{
+		ShortDescription: item,
+		Price:            price,
+	})
+	t.receipt.TotalAmount = t.receipt.TotalAmount.Add(price)
+	return nil
+}
+
+// "Given" function that will register a cap on the points of a receipt, or of the listed rules when there are any, the cap is removed after the scenario
+func (t *ReceiptRewardsTest) aLimitCapsPointsAt(limitName string, rules string, maxPoints int) error {
+	var ruleNames []string
+	for _, ruleName := range strings.Split(rules, ",") {
+		if trimmedRuleName := strings.TrimSpace(ruleName); trimmedRuleName != "" {
+			ruleNames = append(ruleNames, trimmedRuleName)
+		}
+	}
+	limit, err := processor.NewPointsLimit(limitName, ruleNames, nil, &maxPoints)
+	if err != nil {
+		return err
+	}
+	if err := processor.DefaultRegistry().RegisterLimit(limit); err != nil {
+		return err
+	}
+	t.addedLimits = append(t.addedLimits, limitName)
+	return nil
+}
+
+// "Given" function that will replace the rules with those in a rule file at the root of the repository, the rules are put back after the scenario
+func (t *ReceiptRewardsTest) theRulesAreLoadedFromTheRuleFile(fileName string) error {
+	ruleSetConfig, err := config.LoadRuleSet(filepath.Join("..", "..", fileName))
+	if err != nil {
+		return err
+	}
+	return t.replaceConfiguredRules(ruleSetConfig)
+}
+
+// "Given" function that will write a rule file to a new temporary directory and replace the rules with those in it, the directory is removed
+// and the rules are put back after the scenario
+func (t *ReceiptRewardsTest) theRulesAreLoadedFromARuleFile(format string, contents *godog.DocString) error {
+	ruleFileDir, err := os.MkdirTemp("", "rules")
+	if err != nil {
+		return err
+	}
+	t.ruleFilePath = filepath.Join(ruleFileDir, "rules."+format)
+	if err := os.WriteFile(t.ruleFilePath, []byte(contents.Content), 0o644); err != nil {
+		return err
+	}
+	return t.reloadRules()
+}
+
+// "Then" function that will check a rule file fails to load or build with an error mentioning a message, the rules are left as they are
+func (t *ReceiptRewardsTest) loadingARuleFileShouldFailWith(format string, message string, contents *godog.DocString) error {
+	ruleFileDir, err := os.MkdirTemp("", "rules")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(ruleFileDir)
+	ruleFilePath := filepath.Join(ruleFileDir, "rules."+format)
+	if err := os.WriteFile(ruleFilePath, []byte(contents.Content), 0o644); err != nil {
+		return err
+	}
+
+	ruleSetConfig, err := config.LoadRuleSet(ruleFilePath)
+	if err == nil {
+		_, err = processor.BuildRuleSet(ruleSetConfig)
+	}
+	if err == nil {
+		return fmt.Errorf("expected the rule file to fail to load with %q but it loaded", message)
+	}
+	if !strings.Contains(err.Error(), message) {
+		return fmt.Errorf("expected the rule file to fail to load with %q but got %v", message, err)
+	}
+	return nil
+}
+
+// "Then" function that will check a validation file fails to load with an error mentioning a message
+func (t *ReceiptRewardsTest) loadingTheValidationProfilesShouldFailWith(message string, contents *godog.DocString) error {
+	validationFileDir, err := os.MkdirTemp("", "validation")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(validationFileDir)
+	validationFilePath := filepath.Join(validationFileDir, "validation.yml")
+	if err := os.WriteFile(validationFilePath, []byte(contents.Content), 0o644); err != nil {
+		return err
+	}
+
+	_, err = validator.LoadProfiles(validationFilePath)
+	if err == nil {
+		return fmt.Errorf("expected the validation profiles to fail to load with %q but they loaded", message)
+	}
+	if !strings.Contains(err.Error(), message) {
+		return fmt.Errorf("expected the validation profiles to fail to load with %q but got %v", message, err)
+	}
+	return nil
+}
+
+// "Given" function that will reload the rules whenever the rule file changes, as the server does while it is running
+func (t *ReceiptRewardsTest) theRuleFileIsWatchedForChanges() error {
+	if t.ruleFilePath == "" {
+		return fmt.Errorf("the rules have to be loaded from a rule file before it can be watched")
+	}
+	ruleReloads := make(chan error, 1)
+	config.WatchRuleSet(t.ruleFilePath, func(ruleSetConfig *config.RuleSetConfig, err error) {
+		if err == nil {
+			err = t.replaceConfiguredRules(ruleSetConfig)
+		}
+		// a reload nobody waits for is dropped so the watcher never blocks
+		select {
+		case ruleReloads <- err:
+		default:
+		}
+	})
+	t.ruleReloads = ruleReloads
+	return nil
+}
+
+// "When" function that will change the watched rule file and wait for the change to be reloaded, the file is replaced in one step so it is never read half written
+func (t *ReceiptRewardsTest) theRuleFileIsChangedTo(contents *godog.DocString) error {
+	if t.ruleReloads == nil {
+		return fmt.Errorf("the rule file has to be watched before it is changed")
+	}
+	temporaryPath := t.ruleFilePath + ".tmp"
+	if err := os.WriteFile(temporaryPath, []byte(contents.Content), 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(temporaryPath, t.ruleFilePath); err != nil {
+		return err
+	}
+	select {
+	case t.ruleReloadErr = <-t.ruleReloads:
+		return nil
+	case <-time.After(5 * time.Second):
+		return fmt.Errorf("the rule file change wasn't reloaded")
+	}
+}
+
+// "Then" function that will check the change to the rule file was rejected
+func (t *ReceiptRewardsTest) theRuleFileChangeShouldBeRejected() error {
+	if t.ruleReloadErr == nil {
+		return fmt.Errorf("expected the rule file change to be rejected but it was applied")
+	}
+	return nil
+}
+
+// "Then" function that will check the submitted receipt recorded the version of the rule file it was scored with
+func (t *ReceiptRewardsTest) theReceiptShouldRecordTheVersionOfTheRuleFile() error {
+	ruleSetConfig, err := config.LoadRuleSet(t.ruleFilePath)
+	if err != nil {
+		return err
+	}
+	if recordedVersion := t.ruleSetVersions[len(t.ruleSetVersions)-1]; recordedVersion != ruleSetConfig.Version {
+		return fmt.Errorf("expected the receipt to record rule set version %s but got %s", ruleSetConfig.Version, recordedVersion)
+	}
+	return nil
+}
+
+// "Then" function that will check the rule set the first receipt was scored with is still kept under it's own version after the rules changed
+func (t *ReceiptRewardsTest) theRuleSetTheFirstReceiptWasScoredWithShouldStillBeKept() error {
+	firstVersion, latestVersion := t.ruleSetVersions[0], t.ruleSetVersions[len(t.ruleSetVersions)-1]
+	if firstVersion == latestVersion {
+		return fmt.Errorf("expected the receipts to be scored with different rule set versions but both got %s", firstVersion)
+	}
+	if _, err := processor.DefaultRegistry().RuleSet(firstVersion); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Function to load the rule file and replace the rules with those in it
+func (t *ReceiptRewardsTest) reloadRules() error {
+	ruleSetConfig, err := config.LoadRuleSet(t.ruleFilePath)
+	if err != nil {
+		return err
+	}
+	return t.replaceConfiguredRules(ruleSetConfig)
+}
+
+// Function to build the rules in a rule set config and replace the rules with them
+func (t *ReceiptRewardsTest) replaceConfiguredRules(ruleSetConfig *config.RuleSetConfig) error {
+	ruleSet, err := processor.BuildRuleSet(ruleSetConfig)
+	if err != nil {
+		return err
+	}
+	return t.replaceRules(ruleSet)
+}
+
+// Function to replace the rules of the default registry, keeping the rules it had first so they can be put back after the scenario
+func (t *ReceiptRewardsTest) replaceRules(ruleSet processor.RuleSet) error {
+	if t.replacedRuleSet == nil {
+		replacedRuleSet := processor.DefaultRegistry().Snapshot()
+		t.replacedRuleSet = &replacedRuleSet
+	}
+	return processor.DefaultRegistry().Replace(ruleSet)
+}
+
+// "Then" function that will check that a rule is missing from the points breakdown
+func (t *ReceiptRewardsTest) theBreakdownShouldNotShowRule(ruleName string) error {
+	for _, ruleBreakdown := range t.breakdown {
+		if ruleBreakdown.Rule == ruleName {
+			return fmt.Errorf("expected rule %s to be missing from the points breakdown but it contributed %d points", ruleName, ruleBreakdown.Points)
+		}
+	}
+	return nil
+}
+
+// "Then" function that will check which promotion a rule in the points breakdown applied
+func (t *ReceiptRewardsTest) theBreakdownShouldShowRuleAppliedPromotion(ruleName string, promotionName string) error {
+	for _, ruleBreakdown := range t.breakdown {
+		if ruleBreakdown.Rule == ruleName {
+			if ruleBreakdown.Promotion != promotionName {
+				return fmt.Errorf("expected rule %s to apply promotion %q but got %q", ruleName, promotionName, ruleBreakdown.Promotion)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("expected rule %s in the points breakdown but it was missing", ruleName)
+}
+
 // "Given" function that will set the items array with multiple items and total (Can't be used with any other "Given" statements that set the items array or the total)
 func (t *ReceiptRewardsTest) iHaveAReceiptWithItems(itemCount int, items string, total string) error {
-	totalWithoutPrefix := strings.TrimPrefix(total, "$")
-	totalAsFloat, err := strconv.ParseFloat(totalWithoutPrefix, 64)
+	totalAmount, err := parseAmount(total)
 	if err != nil {
 		return err
 	}
-	splitPrice := totalAsFloat / float64(itemCount)
-	priceStr := strconv.FormatFloat(splitPrice, 'f', -1, 64)
+	splitPrice := model.NewMoney(totalAmount.Cents() / int64(itemCount))
 
 	splitItems := strings.Split(items, ",")
 	itemList := []model.ReceiptItem{}
 	for _, item := range splitItems {
 		receiptItem := model.ReceiptItem{
-			item,
-			priceStr,
+			ShortDescription: item,
+			Price:            splitPrice,
 		}
 		itemList = append(itemList, receiptItem)
 	}
 	t.receipt.Items = itemList
-	t.receipt.TotalAmount = total
+	t.receipt.TotalAmount = totalAmount
 	return nil
 }
 
 // "Given" function that will set the items array with a single item and total (Can't be used with any other "Given" statements that set the items array or the total)
 func (t *ReceiptRewardsTest) iHaveAReceiptWithAnItem(item string, total string) error {
-	totalWithoutPrefix := strings.TrimPrefix(total, "$")
+	totalAmount, err := parseAmount(total)
+	if err != nil {
+		return err
+	}
 
 	itemList := []model.ReceiptItem{}
 	newItem := model.ReceiptItem{
-		item,
-		totalWithoutPrefix,
+		ShortDescription: item,
+		Price:            totalAmount,
 	}
 	itemList = append(itemList, newItem)
 	t.receipt.Items = itemList
-	t.receipt.TotalAmount = total
+	t.receipt.TotalAmount = totalAmount
 	return nil
 }
 
+// Function that converts an amount from a feature file such as $100 or 12.99 into money
+func parseAmount(amount string) (model.Money, error) {
+	amountWithoutPrefix := strings.TrimPrefix(amount, "$")
+	amountAsFloat, err := strconv.ParseFloat(amountWithoutPrefix, 64)
+	if err != nil {
+		return model.Money{}, err
+	}
+	return model.NewMoney(int64(math.Round(amountAsFloat * 100))), nil
+}
+
 // "Then" function that will check that the result from processing the receipt was a particular point value
 func (t *ReceiptRewardsTest) theTotalPointsShouldBe(points string) error {
 	expectedPoints, err := strconv.ParseInt(points, 10, 64)
@@ -106,17 +488,31 @@ func (t *ReceiptRewardsTest) theTotalPointsShouldBe(points string) error {
 		return err
 	}
 	if t.pointsEarned != expectedPoints {
-		fmt.Errorf("expected %d points but got %d", expectedPoints, t.pointsEarned)
+		return fmt.Errorf("expected %d points but got %d", expectedPoints, t.pointsEarned)
 	}
 
 	return nil
 }
 
+// "Then" function that will check that a rule in the points breakdown contributed a particular point value
+func (t *ReceiptRewardsTest) theBreakdownShouldShowRuleContributingPoints(ruleName string, points int) error {
+	for _, ruleBreakdown := range t.breakdown {
+		if ruleBreakdown.Rule == ruleName {
+			if ruleBreakdown.Points != points {
+				return fmt.Errorf("expected rule %s to contribute %d points but got %d", ruleName, points, ruleBreakdown.Points)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("expected rule %s in the points breakdown but it was missing", ruleName)
+}
+
 // "When" function that will submit the receipt for processing and save the results
 func (t *ReceiptRewardsTest) iSubmitTheReceipt() error {
-	theLogger := logger.GetLogger()
-	receiptRepo := repository.NewRepository(theLogger)
-	receiptService := service.NewService(receiptRepo, theLogger)
+	if err := t.openReceiptRepository(); err != nil {
+		return err
+	}
+	receiptService := t.receiptService
 
 	processedReceipt, err := receiptService.ProcessReceipt(context.Background(), &t.receipt)
 
@@ -130,8 +526,486 @@ func (t *ReceiptRewardsTest) iSubmitTheReceipt() error {
 		return err
 	}
 
+	t.receiptId = foundReceipt.ID()
 	t.pointsEarned = int64(foundReceipt.Points())
+	t.breakdown = foundReceipt.Breakdown()
+	t.ruleSetVersions = append(t.ruleSetVersions, foundReceipt.RuleSetVersion())
+
+	return nil
+}
+
+// "Given" function that will store the receipts submitted in the scenario in a new SQLite database file
+func (t *ReceiptRewardsTest) theReceiptsAreStoredInASQLiteDatabase() error {
+	return t.storeReceiptsIn(&config.StorageConfig{Backend: repository.SQLiteBackend})
+}
+
+// "Given" function that will keep the receipts submitted in the scenario in memory and write them to a new log directory
+func (t *ReceiptRewardsTest) theReceiptsAreStoredInMemoryWithAWriteAheadLog() error {
+	return t.storeReceiptsIn(&config.StorageConfig{Backend: repository.InMemoryBackend})
+}
+
+// "Given" function that will set how many writes the receipt log takes before it is compacted into a snapshot
+func (t *ReceiptRewardsTest) theReceiptLogIsSnapshottedAfterEveryWrites(writes int) error {
+	if t.storageConfig == nil || t.storageConfig.LogDir == "" {
+		return fmt.Errorf("only receipts stored in memory with a write-ahead log are snapshotted")
+	}
+	t.storageConfig.SnapshotEvery = writes
+	return nil
+}
+
+// "Given" function that will store the receipts submitted in the scenario in a new schema of the PostgreSQL database at POSTGRES_TEST_DSN
+func (t *ReceiptRewardsTest) theReceiptsAreStoredInAPostgreSQLDatabase() error {
+	testDSN := os.Getenv("POSTGRES_TEST_DSN")
+	if testDSN == "" {
+		return fmt.Errorf("POSTGRES_TEST_DSN must point at a PostgreSQL database to store receipts in it")
+	}
+	adminDatabase, err := sql.Open("pgx", testDSN)
+	if err != nil {
+		return err
+	}
+	schema := "receipts_test_" + strings.ReplaceAll(uuid.New().String(), "-", "")
+	if _, err := adminDatabase.Exec("CREATE SCHEMA " + schema); err != nil {
+		adminDatabase.Close()
+		return err
+	}
+	t.postgresAdmin = adminDatabase
+	t.postgresSchema = schema
+
+	postgresConfig := config.LoadPostgres()
+	postgresConfig.DSN = withSearchPath(testDSN, schema)
+	t.storageConfig = &config.StorageConfig{Backend: repository.PostgresBackend, Postgres: postgresConfig}
+	return nil
+}
+
+// Function to add a search path to a PostgreSQL connection string in either url or keyword form, so the tables are created in the schema
+func withSearchPath(dsn string, schema string) string {
+	if !strings.HasPrefix(dsn, "postgres://") && !strings.HasPrefix(dsn, "postgresql://") {
+		return dsn + " search_path=" + schema
+	}
+	if strings.Contains(dsn, "?") {
+		return dsn + "&search_path=" + schema
+	}
+	return dsn + "?search_path=" + schema
+}
+
+// Function to drop the PostgreSQL schema the receipts of the scenario were stored in, if they were stored in PostgreSQL
+func (t *ReceiptRewardsTest) dropPostgresSchema() error {
+	if t.postgresAdmin == nil {
+		return nil
+	}
+	_, dropErr := t.postgresAdmin.Exec("DROP SCHEMA " + t.postgresSchema + " CASCADE")
+	closeErr := t.postgresAdmin.Close()
+	t.postgresAdmin = nil
+	t.postgresSchema = ""
+	return errors.Join(dropErr, closeErr)
+}
+
+// Function to store the receipts of the scenario with a storage config pointing at a new temporary directory, which is removed after the scenario
+func (t *ReceiptRewardsTest) storeReceiptsIn(storageConfig *config.StorageConfig) error {
+	storageDir, err := os.MkdirTemp("", "receipts")
+	if err != nil {
+		return err
+	}
+	storageConfig.SQLitePath = filepath.Join(storageDir, "receipts.db")
+	storageConfig.LogDir = filepath.Join(storageDir, "log")
+	t.storageDir = storageDir
+	t.storageConfig = storageConfig
+	return nil
+}
+
+// "When" function that will reopen the storage and the service as they would be when the application restarts
+func (t *ReceiptRewardsTest) theApplicationIsRestarted() error {
+	if t.storageConfig == nil {
+		return fmt.Errorf("only receipts stored on disk survive a restart")
+	}
+	return t.openReceiptRepository()
+}
+
+// "Then" function that will check the submitted receipt can still be found with the points and breakdown it was given
+func (t *ReceiptRewardsTest) theReceiptShouldStillHaveItsPointsAndBreakdown() error {
+	foundReceipt, err := t.receiptService.FindReceiptById(context.Background(), t.receiptId)
+	if err != nil {
+		return err
+	}
+	if int64(foundReceipt.Points()) != t.pointsEarned {
+		return fmt.Errorf("expected the stored receipt to have %d points but got %d", t.pointsEarned, foundReceipt.Points())
+	}
+	if !reflect.DeepEqual(foundReceipt.Breakdown(), t.breakdown) {
+		return fmt.Errorf("expected the stored breakdown %v but got %v", t.breakdown, foundReceipt.Breakdown())
+	}
+	if !reflect.DeepEqual(foundReceipt.Receipt().Items, t.receipt.Items) {
+		return fmt.Errorf("expected the stored items %v but got %v", t.receipt.Items, foundReceipt.Receipt().Items)
+	}
+	return nil
+}
+
+// Function to open the receipt storage of the scenario, in memory unless the scenario stores receipts on disk, and a service using it
+func (t *ReceiptRewardsTest) openReceiptRepository() error {
+	theLogger := logger.GetLogger()
+	if err := t.closeReceiptRepository(); err != nil {
+		return err
+	}
+	if t.storageConfig == nil {
+		t.receiptRepo = repository.NewInMemoryRepository(theLogger)
+	} else {
+		receiptRepo, err := repository.NewReceiptRepository(t.storageConfig, theLogger)
+		if err != nil {
+			return err
+		}
+		t.receiptRepo = receiptRepo
+	}
+	t.receiptService = service.NewService(t.receiptRepo, theLogger)
+	return nil
+}
+
+// Function to close the receipt storage of the scenario if it has to be closed
+func (t *ReceiptRewardsTest) closeReceiptRepository() error {
+	closer, isCloser := t.receiptRepo.(io.Closer)
+	t.receiptRepo = nil
+	if !isCloser {
+		return nil
+	}
+	return closer.Close()
+}
+
+// "When" function that will submit the receipt again to the service the receipt was first submitted to and save the error
+func (t *ReceiptRewardsTest) iSubmitTheReceiptAgain() error {
+	if t.receiptService == nil {
+		return fmt.Errorf("the receipt has to be submitted before it can be submitted again")
+	}
+	t.resubmitted, t.submitErr = t.receiptService.ProcessReceipt(context.Background(), &t.receipt)
+	return nil
+}
+
+// "Then" function that will check the resubmitted receipt was flagged for fraud review as similar to the first receipt
+func (t *ReceiptRewardsTest) theReceiptShouldBeFlaggedForFraudReview() error {
+	if t.submitErr != nil {
+		return fmt.Errorf("expected the receipt to be accepted but got %v", t.submitErr)
+	}
+	fraudReview := t.resubmitted.FraudReview()
+	if !fraudReview.Flagged {
+		return fmt.Errorf("expected the receipt to be flagged for fraud review but it scored %.2f", fraudReview.Score)
+	}
+	if fraudReview.SimilarReceiptID != t.receiptId {
+		return fmt.Errorf("expected the receipt to be similar to receipt %s but got %s", t.receiptId, fraudReview.SimilarReceiptID)
+	}
+	return nil
+}
+
+// "Then" function that will check the resubmitted receipt wasn't flagged for fraud review
+func (t *ReceiptRewardsTest) theReceiptShouldNotBeFlaggedForFraudReview() error {
+	if t.submitErr != nil {
+		return fmt.Errorf("expected the receipt to be accepted but got %v", t.submitErr)
+	}
+	if fraudReview := t.resubmitted.FraudReview(); fraudReview.Flagged {
+		return fmt.Errorf("expected the receipt not to be flagged for fraud review but it scored %.2f", fraudReview.Score)
+	}
+	return nil
+}
+
+// "Then" function that will check the resubmitted receipt was rejected as a duplicate of the first receipt
+func (t *ReceiptRewardsTest) theReceiptShouldBeRejectedAsADuplicateOfTheFirstReceipt() error {
+	var duplicateReceiptError *repository.DuplicateReceiptError
+	if !errors.As(t.submitErr, &duplicateReceiptError) {
+		return fmt.Errorf("expected a duplicate receipt error but got %v", t.submitErr)
+	}
+	if duplicateReceiptError.OriginalID != t.receiptId {
+		return fmt.Errorf("expected the duplicate to point at receipt %s but got %s", t.receiptId, duplicateReceiptError.OriginalID)
+	}
+	return nil
+}
+
+// "Then" function that will check the resubmitted receipt was accepted as a new receipt
+func (t *ReceiptRewardsTest) theReceiptShouldBeAcceptedAsANewReceipt() error {
+	if t.submitErr != nil {
+		return fmt.Errorf("expected the receipt to be accepted but got %v", t.submitErr)
+	}
+	return nil
+}
+
+// "When" function that will score the receipt without storing it and save the results
+func (t *ReceiptRewardsTest) iSimulateTheReceipt() error {
+	theLogger := logger.GetLogger()
+	t.receiptRepo = repository.NewInMemoryRepository(theLogger)
+	t.receiptService = service.NewService(t.receiptRepo, theLogger)
+
+	simulatedReceipt, err := t.receiptService.SimulateReceipt(context.Background(), &t.receipt)
+	if err != nil {
+		return err
+	}
+
+	t.pointsEarned = int64(simulatedReceipt.Points())
+	t.breakdown = simulatedReceipt.Breakdown()
+	return nil
+}
+
+// "When" function that will validate the receipt and save the field errors
+func (t *ReceiptRewardsTest) iValidateTheReceipt() error {
+	t.validationErrors = validator.ValidateReceipt(t.receipt)
+	return nil
+}
+
+// "Given" function that will set the validation profile of an api client, the profiles are reset after the scenario
+func (t *ReceiptRewardsTest) theClientUsesTheValidationProfile(clientId string, profileName string) error {
+	profiles, err := validator.NewProfiles(validator.StrictProfileName, nil, map[string]string{clientId: profileName})
+	if err != nil {
+		return err
+	}
+	validator.SetProfiles(profiles)
+	return nil
+}
+
+// "Given" function that will give an api client a profile allowing the subtotal and total to be off by up to a tolerance, the profiles are reset after the scenario
+func (t *ReceiptRewardsTest) theClientAllowsATotalToleranceOf(clientId string, toleranceStr string) error {
+	tolerance, err := model.ParseMoney(toleranceStr)
+	if err != nil {
+		return err
+	}
+	profile, err := validator.NewCustomProfile(clientId+"-tolerance", validator.StrictProfile(), "", "")
+	if err != nil {
+		return err
+	}
+	profiles, err := validator.NewProfiles(validator.StrictProfileName, []*validator.Profile{profile.WithTolerance(tolerance)}, map[string]string{clientId: profile.Name})
+	if err != nil {
+		return err
+	}
+	validator.SetProfiles(profiles)
+	return nil
+}
+
+// "Given" function that will set the subtotal, tax, a discount and the tip on the receipt (Can be used with other given statements)
+func (t *ReceiptRewardsTest) iHaveAReceiptWithASubtotalTaxDiscountAndTip(subtotalStr string, taxStr string, discountStr string, tipStr string) error {
+	amounts := make([]model.Money, 0, 4)
+	for _, amountStr := range []string{subtotalStr, taxStr, discountStr, tipStr} {
+		amount, err := model.ParseMoney(amountStr)
+		if err != nil {
+			return err
+		}
+		amounts = append(amounts, amount)
+	}
+	t.receipt.Subtotal = &amounts[0]
+	t.receipt.Tax = &amounts[1]
+	t.receipt.Discounts = []model.Discount{{Description: "coupon", Amount: amounts[2]}}
+	t.receipt.Tip = &amounts[3]
+	return nil
+}
+
+// "Given" function that will set only the total on the receipt, leaving the items as they are (Can be used with other given statements)
+func (t *ReceiptRewardsTest) iHaveAReceiptWithAFinalTotalOf(totalStr string) error {
+	total, err := model.ParseMoney(totalStr)
+	if err != nil {
+		return err
+	}
+	t.receipt.TotalAmount = total
+	return nil
+}
+
+// "Given" function that will fix the clock purchase dates are validated against, the clock is reset after the scenario
+func (t *ReceiptRewardsTest) theCurrentTimeIs(currentTimeStr string) error {
+	currentTime, err := time.Parse("2006-01-02 15:04", currentTimeStr)
+	if err != nil {
+		return err
+	}
+	validator.SetClock(func() time.Time {
+		return currentTime
+	})
+	return nil
+}
+
+// "Given" function that will give an api client a profile that rejects receipts older than a number of days, the profiles are reset after the scenario
+func (t *ReceiptRewardsTest) theClientHasASubmissionWindowOfDays(clientId string, days int) error {
+	profile, err := validator.NewCustomProfile(clientId+"-window", validator.StrictProfile(), "", "")
+	if err != nil {
+		return err
+	}
+	windowedProfile := profile.WithSubmissionWindow(time.Duration(days) * 24 * time.Hour)
+	profiles, err := validator.NewProfiles(validator.StrictProfileName, []*validator.Profile{windowedProfile}, map[string]string{clientId: windowedProfile.Name})
+	if err != nil {
+		return err
+	}
+	validator.SetProfiles(profiles)
+	return nil
+}
+
+// "When" function that will validate the receipt with the validation profile of an api client and save the field errors
+func (t *ReceiptRewardsTest) iValidateTheReceiptFromClient(clientId string) error {
+	t.validationErrors = validator.ValidateReceiptWithProfile(t.receipt, validator.ProfileForClient(clientId))
+	return nil
+}
+
+// "Then" function that will check the validation reported an error code for a field
+func (t *ReceiptRewardsTest) theValidationShouldReportAt(code string, path string) error {
+	for _, fieldError := range t.validationErrors {
+		if fieldError.Path == path && fieldError.Code == code {
+			return nil
+		}
+	}
+	return fmt.Errorf("expected a %s error at %s but got %v", code, path, t.validationErrors)
+}
+
+// "Then" function that will check the number of fields that failed validation
+func (t *ReceiptRewardsTest) theValidationShouldReportErrors(count int) error {
+	if len(t.validationErrors) != count {
+		return fmt.Errorf("expected %d validation errors but got %d: %v", count, len(t.validationErrors), t.validationErrors)
+	}
+	return nil
+}
+
+// "Then" function that will check that no receipts were stored
+func (t *ReceiptRewardsTest) noReceiptsShouldBeStored() error {
+	receipts, err := t.receiptRepo.FindAll(context.Background())
+	if err != nil {
+		return err
+	}
+	if len(receipts) != 0 {
+		return fmt.Errorf("expected no stored receipts but got %d", len(receipts))
+	}
+	return nil
+}
+
+// "When" function that will recalculate the stored receipts under a rule set version
+func (t *ReceiptRewardsTest) iRecalculateTheStoredReceiptsWithRuleSetVersion(ruleSetVersion string) error {
+	_, err := t.receiptService.RecalculateReceipts(context.Background(), ruleSetVersion)
+	return err
+}
+
+// "Then" function that will check that the latest recalculation of the receipt kept the original points and recalculated to a particular point value
+func (t *ReceiptRewardsTest) theRecalculatedPointsShouldBeWithTheOriginalPointsKept(points int) error {
+	foundReceipt, err := t.receiptService.FindReceiptById(context.Background(), t.receiptId)
+	if err != nil {
+		return err
+	}
+
+	recalculation, exists := foundReceipt.LatestRecalculation()
+	if !exists {
+		return fmt.Errorf("expected receipt %s to have been recalculated", t.receiptId)
+	}
+	if recalculation.OriginalPoints != int(t.pointsEarned) || foundReceipt.Points() != int(t.pointsEarned) {
+		return fmt.Errorf("expected the original points %d to be kept but got %d", t.pointsEarned, recalculation.OriginalPoints)
+	}
+	if recalculation.RecalculatedPoints != points {
+		return fmt.Errorf("expected %d recalculated points but got %d", points, recalculation.RecalculatedPoints)
+	}
+	return nil
+}
+
+// "Given" function that will set the token the admin endpoints of the api need
+func (t *ReceiptRewardsTest) theAdminTokenIs(adminToken string) error {
+	t.adminToken = adminToken
+	return nil
+}
+
+// "When" function that will send the receipt as json to an endpoint of the api and save the response
+func (t *ReceiptRewardsTest) iSendTheReceiptTo(path string) error {
+	body, err := json.Marshal(t.receipt)
+	if err != nil {
+		return err
+	}
+	return t.sendRequest(path, string(body), nil)
+}
+
+// "When" function that will send the receipt to an endpoint of the api as an api client and save the response
+func (t *ReceiptRewardsTest) iSendTheReceiptToFromClient(path string, clientId string) error {
+	body, err := json.Marshal(t.receipt)
+	if err != nil {
+		return err
+	}
+	return t.sendRequest(path, string(body), map[string]string{"X-Client-ID": clientId})
+}
+
+// "When" function that will send a request body to an endpoint of the api and save the response
+func (t *ReceiptRewardsTest) iSendARequestToWithTheBody(path string, body *godog.DocString) error {
+	return t.sendRequest(path, body.Content, nil)
+}
 
+// "When" function that will ask the api to recalculate the stored receipts with a rule set version, sending the admin token when one is given
+func (t *ReceiptRewardsTest) iSendARecalculationRequestForRuleSetVersion(ruleSetVersion string, adminToken string) error {
+	body, err := json.Marshal(map[string]string{"ruleSetVersion": ruleSetVersion})
+	if err != nil {
+		return err
+	}
+	headers := map[string]string{}
+	if adminToken != "" {
+		headers["Authorization"] = "Bearer " + adminToken
+	}
+	return t.sendRequest("/receipts/admin/recalculate", string(body), headers)
+}
+
+// Function to send a POST request to the receipt router, which uses the service of the scenario so it sees the receipts submitted in it
+func (t *ReceiptRewardsTest) sendRequest(path string, body string, headers map[string]string) error {
+	if t.receiptService == nil {
+		if err := t.openReceiptRepository(); err != nil {
+			return err
+		}
+	}
+	router := routes.NewReceiptRouter(handler.NewHandler(t.receiptService, logger.GetLogger()), t.adminToken)
+
+	request := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		request.Header.Set(name, value)
+	}
+	t.response = httptest.NewRecorder()
+	router.ServeHTTP(t.response, request)
+	return nil
+}
+
+// "Then" function that will check the status of the api response
+func (t *ReceiptRewardsTest) theResponseStatusShouldBe(status int) error {
+	if t.response.Code != status {
+		return fmt.Errorf("expected a %d response but got %d: %s", status, t.response.Code, t.response.Body.String())
+	}
+	return nil
+}
+
+// "Then" function that will check the content type of the api response
+func (t *ReceiptRewardsTest) theResponseContentTypeShouldBe(contentType string) error {
+	if responseContentType := t.response.Header().Get("Content-Type"); responseContentType != contentType {
+		return fmt.Errorf("expected a %s response but got %s", contentType, responseContentType)
+	}
+	return nil
+}
+
+// "Then" function that will check the problem details of the api response list an error code for a field
+func (t *ReceiptRewardsTest) theResponseShouldReportAt(code string, path string) error {
+	var problem model.ValidationProblemResponse
+	if err := json.Unmarshal(t.response.Body.Bytes(), &problem); err != nil {
+		return fmt.Errorf("expected a problem details response but got %s: %w", t.response.Body.String(), err)
+	}
+	for _, fieldError := range problem.Errors {
+		if fieldError.Path == path && fieldError.Code == code {
+			return nil
+		}
+	}
+	return fmt.Errorf("expected a %s error at %s in the response but got %v", code, path, problem.Errors)
+}
+
+// "Then" function that will check the problem type of the api response
+func (t *ReceiptRewardsTest) theResponseProblemTypeShouldBe(problemType string) error {
+	var problem model.ProblemResponse
+	if err := json.Unmarshal(t.response.Body.Bytes(), &problem); err != nil {
+		return fmt.Errorf("expected a problem details response but got %s: %w", t.response.Body.String(), err)
+	}
+	if problem.Type != problemType {
+		return fmt.Errorf("expected a %s problem but got %s", problemType, problem.Type)
+	}
+	return nil
+}
+
+// "When" function that will close the receipt storage while the service still writes to it, so storing another receipt fails
+func (t *ReceiptRewardsTest) theReceiptStorageIsClosed() error {
+	return t.closeReceiptRepository()
+}
+
+// "Then" function that will check the stored receipt wasn't recalculated
+func (t *ReceiptRewardsTest) theReceiptShouldNotBeRecalculated() error {
+	foundReceipt, err := t.receiptService.FindReceiptById(context.Background(), t.receiptId)
+	if err != nil {
+		return err
+	}
+	if recalculations := foundReceipt.Recalculations(); len(recalculations) != 0 {
+		return fmt.Errorf("expected receipt %s not to be recalculated but it has %d recalculations", t.receiptId, len(recalculations))
+	}
 	return nil
 }
 
@@ -139,25 +1013,168 @@ func (t *ReceiptRewardsTest) iSubmitTheReceipt() error {
 func InitializeScenario(ctx *godog.ScenarioContext) {
 	test := &ReceiptRewardsTest{}
 
+	ctx.After(func(ctx context.Context, scenario *godog.Scenario, err error) (context.Context, error) {
+		for _, ruleName := range test.addedRules {
+			if removeErr := processor.DefaultRegistry().Remove(ruleName); removeErr != nil {
+				return ctx, removeErr
+			}
+		}
+		test.addedRules = nil
+		for _, programName := range test.addedPrograms {
+			if removeErr := processor.DefaultRegistry().RemoveRetailerProgram(programName); removeErr != nil {
+				return ctx, removeErr
+			}
+		}
+		test.addedPrograms = nil
+		for _, limitName := range test.addedLimits {
+			if removeErr := processor.DefaultRegistry().RemoveLimit(limitName); removeErr != nil {
+				return ctx, removeErr
+			}
+		}
+		test.addedLimits = nil
+		if test.replacedRuleSet != nil {
+			if replaceErr := processor.DefaultRegistry().Replace(*test.replacedRuleSet); replaceErr != nil {
+				return ctx, replaceErr
+			}
+			test.replacedRuleSet = nil
+		}
+		if test.ruleFilePath != "" {
+			if removeErr := os.RemoveAll(filepath.Dir(test.ruleFilePath)); removeErr != nil {
+				return ctx, removeErr
+			}
+		}
+		test.ruleFilePath = ""
+		test.ruleReloads = nil
+		test.ruleReloadErr = nil
+		test.ruleSetVersions = nil
+		test.adminToken = ""
+		test.response = nil
+		test.interruptedRepo = nil
+		processor.SetTimeZoneResolver(timezone.NewResolver(time.UTC, nil))
+		processor.SetExchangeRateProvider(currency.NewStaticProvider(currency.DefaultCurrency, nil))
+		defaultProfiles, profilesErr := validator.NewProfiles(validator.StrictProfileName, nil, nil)
+		if profilesErr != nil {
+			return ctx, profilesErr
+		}
+		validator.SetProfiles(defaultProfiles)
+		validator.SetClock(time.Now)
+		if closeErr := test.closeReceiptRepository(); closeErr != nil {
+			return ctx, closeErr
+		}
+		if test.storageDir != "" {
+			if removeErr := os.RemoveAll(test.storageDir); removeErr != nil {
+				return ctx, removeErr
+			}
+		}
+		if dropErr := test.dropPostgresSchema(); dropErr != nil {
+			return ctx, dropErr
+		}
+		test.storageConfig = nil
+		test.storageDir = ""
+		return ctx, nil
+	})
+
 	ctx.Given(`I have a valid receipt with the required information`, test.iHaveAValidReceipt)
 	ctx.Given(`I have a receipt with a retailer name "([^"]*)"`, test.iHaveAReceiptWithRetailerName)
-	ctx.Given(`I have a receipt with a total of (\d+)`, test.iHaveAReceiptWithATotal)
-	ctx.Given(`I have a receipt with (\d+) items "([^"]*)" and a final total of (\d+)`, test.iHaveAReceiptWithItems)
-	ctx.Given(`I have a receipt with an item "([^"]*)" priced at (\d+)`, test.iHaveAReceiptWithAnItem)
+	ctx.Given(`^I have a receipt with a total of ([\d.]+)$`, test.iHaveAReceiptWithATotal)
+	ctx.Given(`^I have a receipt with (\d+) items "([^"]*)" and a final total of ([\d.]+)$`, test.iHaveAReceiptWithItems)
+	ctx.Given(`^I have a receipt with an item "([^"]*)" priced at ([\d.]+)$`, test.iHaveAReceiptWithAnItem)
 	ctx.Given(`I have a receipt with a purchase date of "([^"]*)"`, test.iHaveAReceiptWithAPurchaseDate)
 	ctx.Given(`I have a receipt with a purchase time of "([^"]*)"`, test.iHaveAReceiptWithAPurchaseTime)
+	ctx.Given(`a "([^"]*)" promotion awards (\d+) bonus points from "([^"]*)" to "([^"]*)"`, test.aPromotionAwardsBonusPointsFromTo)
+	ctx.Given(`a "([^"]*)" retailer program matching "([^"]*)" by (exact|case-insensitive|regex) awards ([\d.]+)x points and (\d+) bonus points`, test.aRetailerProgramMatchingAwardsPointsAndBonusPoints)
+	ctx.Given(`a "([^"]*)" product bonus awards (\d+) points per unit of sku "([^"]*)"`, test.aProductBonusAwardsPointsPerUnitOfSKU)
+	ctx.Given(`I have a receipt with (\d+) units of an item "([^"]*)" with sku "([^"]*)" priced at ([\d.]+) each`, test.iHaveAReceiptWithUnitsOfAnItemWithSKUPricedAt)
+	ctx.Given(`a "([^"]*)" limit caps the points of rules "([^"]*)" at (\d+) points`, test.aLimitCapsPointsAt)
+	ctx.Given(`a "([^"]*)" limit caps the points of the receipt()? at (\d+) points`, test.aLimitCapsPointsAt)
+	ctx.Given(`I have a receipt with a time zone of "([^"]*)"`, test.iHaveAReceiptWithATimeZoneOf)
+	ctx.Given(`the retailer "([^"]*)" is in the "([^"]*)" time zone`, test.theRetailerIsInTheTimeZone)
+	ctx.Given(`I have a receipt with a returned item "([^"]*)" priced at (-[\d.]+)`, test.iHaveAReceiptWithAReturnedItemPricedAt)
+	ctx.Given(`I have a receipt in currency "([^"]*)"`, test.iHaveAReceiptInCurrency)
+	ctx.Given(`the rules are loaded from the rule file "([^"]*)"`, test.theRulesAreLoadedFromTheRuleFile)
+	ctx.Given(`the rules are loaded from a (yaml|json) rule file:`, test.theRulesAreLoadedFromARuleFile)
+	ctx.Then(`loading a (yaml|json) rule file should fail with "([^"]*)":`, test.loadingARuleFileShouldFailWith)
+	ctx.Given(`the rule file is watched for changes`, test.theRuleFileIsWatchedForChanges)
+	ctx.When(`the rule file is changed to:`, test.theRuleFileIsChangedTo)
+	ctx.Then(`the rule file change should be rejected`, test.theRuleFileChangeShouldBeRejected)
+	ctx.Then(`the receipt should record the version of the rule file`, test.theReceiptShouldRecordTheVersionOfTheRuleFile)
+	ctx.Then(`the rule set the first receipt was scored with should still be kept`, test.theRuleSetTheFirstReceiptWasScoredWithShouldStillBeKept)
+	ctx.Given(`the exchange rate from "([^"]*)" to US dollars is ([\d.]+)`, test.theExchangeRateToUSDollarsIs)
+
+	ctx.Given(`the receipts are stored in a SQLite database`, test.theReceiptsAreStoredInASQLiteDatabase)
+	ctx.Given(`the receipts are stored in a PostgreSQL database`, test.theReceiptsAreStoredInAPostgreSQLDatabase)
+	ctx.Given(`the receipts are stored in memory with a write-ahead log`, test.theReceiptsAreStoredInMemoryWithAWriteAheadLog)
+	ctx.Given(`the receipt log is snapshotted after every (\d+) writes?`, test.theReceiptLogIsSnapshottedAfterEveryWrites)
 
-	ctx.When(`I submit the receipt`, test.iSubmitTheReceipt)
+	ctx.When(`^I submit the receipt$`, test.iSubmitTheReceipt)
+	ctx.When(`I submit the receipt again`, test.iSubmitTheReceiptAgain)
+	ctx.When(`the application is restarted`, test.theApplicationIsRestarted)
+	ctx.When(`I simulate the receipt`, test.iSimulateTheReceipt)
+	ctx.When(`^I recalculate the stored receipts with rule set version "([^"]*)"$`, test.iRecalculateTheStoredReceiptsWithRuleSetVersion)
+	ctx.Given(`^saving the last recalculated receipt fails after another request recalculates the first one$`, test.savingTheLastRecalculatedReceiptFailsAfterAnotherRequestRecalculatesTheFirstOne)
+	ctx.Then(`^recalculating the stored receipts with rule set version "([^"]*)" should fail$`, test.recalculatingTheStoredReceiptsWithRuleSetVersionShouldFail)
+	ctx.Then(`^only the receipt the other request recalculated should be recalculated$`, test.onlyTheReceiptTheOtherRequestRecalculatedShouldBeRecalculated)
+	ctx.Given(`the "([^"]*)" client uses the "([^"]*)" validation profile`, test.theClientUsesTheValidationProfile)
+	ctx.Given(`the "([^"]*)" client allows a total tolerance of "([^"]*)"`, test.theClientAllowsATotalToleranceOf)
+	ctx.Given(`I have a receipt with a subtotal of "([^"]*)", tax of "([^"]*)", a discount of "([^"]*)" and a tip of "([^"]*)"`, test.iHaveAReceiptWithASubtotalTaxDiscountAndTip)
+	ctx.Given(`I have a receipt with a final total of "([^"]*)"`, test.iHaveAReceiptWithAFinalTotalOf)
+	ctx.Given(`the current time is "([^"]*)"`, test.theCurrentTimeIs)
+	ctx.Given(`the "([^"]*)" client has a submission window of (\d+) days`, test.theClientHasASubmissionWindowOfDays)
+	ctx.When(`^I validate the receipt$`, test.iValidateTheReceipt)
+	ctx.When(`I validate the receipt from client "([^"]*)"`, test.iValidateTheReceiptFromClient)
+	ctx.Then(`the validation should report "([^"]*)" at "([^"]*)"`, test.theValidationShouldReportAt)
+	ctx.Then(`the validation should report (\d+) errors?`, test.theValidationShouldReportErrors)
 	ctx.Then(`the total points should be (\d+)`, test.theTotalPointsShouldBe)
+	ctx.Then(`the breakdown should show rule "([^"]*)" applying the "([^"]*)" promotion`, test.theBreakdownShouldShowRuleAppliedPromotion)
+	ctx.Then(`the breakdown should not show rule "([^"]*)"`, test.theBreakdownShouldNotShowRule)
+	ctx.Then(`the receipt should be rejected as a duplicate of the first receipt`, test.theReceiptShouldBeRejectedAsADuplicateOfTheFirstReceipt)
+	ctx.Then(`the receipt should be accepted as a new receipt`, test.theReceiptShouldBeAcceptedAsANewReceipt)
+	ctx.Then(`the receipt should be flagged for fraud review`, test.theReceiptShouldBeFlaggedForFraudReview)
+	ctx.Then(`the receipt should not be flagged for fraud review`, test.theReceiptShouldNotBeFlaggedForFraudReview)
+	ctx.Then(`the receipt should still have its points and breakdown`, test.theReceiptShouldStillHaveItsPointsAndBreakdown)
+	ctx.Then(`no receipts should be stored`, test.noReceiptsShouldBeStored)
+	ctx.Then(`the recalculated points should be (\d+) with the original points kept`, test.theRecalculatedPointsShouldBeWithTheOriginalPointsKept)
+	ctx.Then(`the breakdown should show rule "([^"]*)" contributing (-?\d+) points`, test.theBreakdownShouldShowRuleContributingPoints)
+
+	ctx.Given(`the admin token is "([^"]*)"`, test.theAdminTokenIs)
+	ctx.When(`^I send the receipt to "([^"]*)"$`, test.iSendTheReceiptTo)
+	ctx.When(`^I send the receipt to "([^"]*)" from client "([^"]*)"$`, test.iSendTheReceiptToFromClient)
+	ctx.When(`I send a request to "([^"]*)" with the body:`, test.iSendARequestToWithTheBody)
+	ctx.When(`^I send a recalculation request for rule set version "([^"]*)" with the admin token "([^"]*)"$`, test.iSendARecalculationRequestForRuleSetVersion)
+	ctx.When(`^I send a recalculation request for rule set version "([^"]*)" without an admin token()$`, test.iSendARecalculationRequestForRuleSetVersion)
+	ctx.Then(`the response status should be (\d+)`, test.theResponseStatusShouldBe)
+	ctx.Then(`the response content type should be "([^"]*)"`, test.theResponseContentTypeShouldBe)
+	ctx.Then(`the response should report "([^"]*)" at "([^"]*)"`, test.theResponseShouldReportAt)
+	ctx.Then(`^loading the validation profiles should fail with "([^"]*)":$`, test.loadingTheValidationProfilesShouldFailWith)
+	ctx.Then(`the response problem type should be "([^"]*)"`, test.theResponseProblemTypeShouldBe)
+	ctx.When(`^the receipt storage is closed$`, test.theReceiptStorageIsClosed)
+	ctx.Then(`the receipt should not be recalculated`, test.theReceiptShouldNotBeRecalculated)
+	ctx.Then(`^the receipts from "([^"]*)" from "([^"]*)" before "([^"]*)" should have the purchase dates "([^"]*)"$`, test.theReceiptsFromBetweenShouldHaveThePurchaseDates)
+
+	InitializeStoreIndexScenario(ctx)
+	InitializeRuleRegistryScenario(ctx)
+	InitializeStorageBackendScenario(ctx)
 }
 
 // Sets up the godog test suite and is the primary test that executes
 func TestFeatures(t *testing.T) {
+	// the postgres scenarios run against the database at POSTGRES_TEST_DSN or an embedded PostgreSQL server, they are
+	// left out when SKIP_POSTGRES_TESTS is set or when the embedded server can't be started, such as on a machine that
+	// can't download it, so the other scenarios still run
+	tags := ""
+	if os.Getenv("SKIP_POSTGRES_TESTS") == "true" {
+		t.Log("SKIP_POSTGRES_TESTS is set, leaving out the postgres scenarios")
+		tags = "~@postgres"
+	} else if err := usePostgresTestDatabase(t); err != nil {
+		t.Logf("leaving out the postgres scenarios: %v", err)
+		tags = "~@postgres"
+	}
 	suite := godog.TestSuite{
 		ScenarioInitializer: InitializeScenario,
 		Options: &godog.Options{
 			Format:   "pretty",
 			Paths:    []string{"../features"},
+			Tags:     tags,
 			TestingT: t,
 		},
 	}
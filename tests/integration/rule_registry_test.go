@@ -0,0 +1,128 @@
+package integration
+
+import (
+	"fmt"
+	"github.com/cucumber/godog"
+	"receipt-processor-challenge/internal/receipt/model"
+	"receipt-processor-challenge/internal/receipt/processor"
+	"strings"
+)
+
+type RuleRegistryTest struct {
+	registry *processor.Registry
+}
+
+// "Given" function that will create a rule registry of its own with the default rules, so the rules receipts are processed with are left alone
+func (t *RuleRegistryTest) aRuleRegistryWithTheDefaultRules() error {
+	t.registry = processor.NewRegistry("registry-test", processor.DefaultRules()...)
+	return nil
+}
+
+// "When" function that will register a rule awarding a fixed number of points
+func (t *RuleRegistryTest) aRuleAwardingPointsIsRegistered(name string, points int) error {
+	return t.registry.Register(fixedPointsRule(name, points))
+}
+
+// "When" function that will remove a rule by its name
+func (t *RuleRegistryTest) ruleIsRemoved(name string) error {
+	return t.registry.Remove(name)
+}
+
+// "When" function that will reorder the rules to a comma separated list of names
+func (t *RuleRegistryTest) theRulesAreReorderedTo(names string) error {
+	return t.registry.Reorder(splitNames(names)...)
+}
+
+// "Then" function that will check registering a rule fails
+func (t *RuleRegistryTest) registeringARuleAwardingPointsShouldFail(name string, points int) error {
+	if err := t.registry.Register(fixedPointsRule(name, points)); err == nil {
+		return fmt.Errorf("expected registering rule %s to fail but it succeeded", name)
+	}
+	return nil
+}
+
+// "Then" function that will check removing a rule fails
+func (t *RuleRegistryTest) removingRuleShouldFail(name string) error {
+	if err := t.registry.Remove(name); err == nil {
+		return fmt.Errorf("expected removing rule %s to fail but it succeeded", name)
+	}
+	return nil
+}
+
+// "Then" function that will check reordering the rules fails
+func (t *RuleRegistryTest) reorderingTheRulesToShouldFail(names string) error {
+	if err := t.registry.Reorder(splitNames(names)...); err == nil {
+		return fmt.Errorf("expected reordering the rules to %q to fail but it succeeded", names)
+	}
+	return nil
+}
+
+// "Then" function that will check the rules in the breakdown of a receipt scored with the registry, in order
+func (t *RuleRegistryTest) theBreakdownShouldListTheRules(names string) error {
+	_, breakdown, err := processor.CalculatePoints(t.registry.Snapshot(), registryTestReceipt())
+	if err != nil {
+		return err
+	}
+	ruleNames := make([]string, 0, len(breakdown))
+	for _, contribution := range breakdown {
+		ruleNames = append(ruleNames, contribution.Rule)
+	}
+	if found := strings.Join(ruleNames, ", "); found != names {
+		return fmt.Errorf("expected the breakdown to list the rules %q but got %q", names, found)
+	}
+	return nil
+}
+
+// "Then" function that will check the points of a receipt scored with the registry
+func (t *RuleRegistryTest) theRegisteredRulesShouldAwardPoints(points int) error {
+	awardedPoints, _, err := processor.CalculatePoints(t.registry.Snapshot(), registryTestReceipt())
+	if err != nil {
+		return err
+	}
+	if awardedPoints != points {
+		return fmt.Errorf("expected %d points but got %d", points, awardedPoints)
+	}
+	return nil
+}
+
+// Function to create a rule that awards the same points to every receipt
+func fixedPointsRule(name string, points int) processor.Rule {
+	return processor.NewRule(name, "Fixed points.", func(receipt *model.Receipt) processor.RuleResult {
+		return processor.RuleResult{Points: points, Reason: fmt.Sprintf("%d fixed points", points)}
+	})
+}
+
+// Function to create a receipt the default rules award no points
+func registryTestReceipt() *model.Receipt {
+	return &model.Receipt{
+		RetailerName: "_",
+		PurchaseDate: "2022-01-02",
+		PurchaseTime: "13:33",
+		TotalAmount:  model.MustParseMoney("0.01"),
+		Items:        []model.ReceiptItem{{ShortDescription: "item", Price: model.MustParseMoney("0.01")}},
+	}
+}
+
+// Function to split a comma separated list of names
+func splitNames(names string) []string {
+	splitNames := strings.Split(names, ",")
+	for index := range splitNames {
+		splitNames[index] = strings.TrimSpace(splitNames[index])
+	}
+	return splitNames
+}
+
+// Initializes the rule registry scenarios, matching their statements with the corresponding handlers
+func InitializeRuleRegistryScenario(ctx *godog.ScenarioContext) {
+	test := &RuleRegistryTest{}
+
+	ctx.Given(`^a rule registry with the default rules$`, test.aRuleRegistryWithTheDefaultRules)
+	ctx.When(`^a "([^"]*)" rule awarding (\d+) points is registered$`, test.aRuleAwardingPointsIsRegistered)
+	ctx.When(`^rule "([^"]*)" is removed$`, test.ruleIsRemoved)
+	ctx.When(`^the rules are reordered to "([^"]*)"$`, test.theRulesAreReorderedTo)
+	ctx.Then(`^registering a "([^"]*)" rule awarding (\d+) points should fail$`, test.registeringARuleAwardingPointsShouldFail)
+	ctx.Then(`^removing rule "([^"]*)" should fail$`, test.removingRuleShouldFail)
+	ctx.Then(`^reordering the rules to "([^"]*)" should fail$`, test.reorderingTheRulesToShouldFail)
+	ctx.Then(`^the breakdown should list the rules "([^"]*)"$`, test.theBreakdownShouldListTheRules)
+	ctx.Then(`^the registered rules should award (\d+) points$`, test.theRegisteredRulesShouldAwardPoints)
+}
@@ -0,0 +1,149 @@
+package integration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/cucumber/godog"
+	"github.com/spf13/viper"
+	"io"
+	"os"
+	"path/filepath"
+	"receipt-processor-challenge/internal/receipt/repository"
+	"receipt-processor-challenge/pkg/config"
+	"receipt-processor-challenge/pkg/logger"
+	"strings"
+)
+
+// the settings the storage backend is chosen with, they are cleared after every scenario
+var storageSettings = []string{"STORAGE_BACKEND", "SQLITE_PATH", "STORE_LOG_DIR"}
+
+type StorageBackendTest struct {
+	storageDir  string
+	receiptRepo repository.ReceiptRepository
+}
+
+// "Given" function that will set the storage backend setting, the SQLite database is kept in a new directory
+func (t *StorageBackendTest) theStorageBackendSettingIs(backend string) error {
+	storageDir, err := os.MkdirTemp("", "storage")
+	if err != nil {
+		return err
+	}
+	t.storageDir = storageDir
+	viper.Set("STORAGE_BACKEND", backend)
+	viper.Set("SQLITE_PATH", filepath.Join(storageDir, "receipts.db"))
+	return nil
+}
+
+// "Given" function that will set the receipt log directory setting to a directory in the storage directory, an empty directory leaves it unset
+func (t *StorageBackendTest) theReceiptLogDirectorySettingIs(logDir string) error {
+	if logDir != "" {
+		logDir = filepath.Join(t.storageDir, logDir)
+	}
+	viper.Set("STORE_LOG_DIR", logDir)
+	return nil
+}
+
+// "When" function that will create the receipt repository for the storage settings
+func (t *StorageBackendTest) theReceiptRepositoryIsCreatedFromTheStorageSettings() error {
+	receiptRepo, err := repository.NewReceiptRepository(config.LoadStorage(), logger.GetLogger())
+	if err != nil {
+		return err
+	}
+	t.receiptRepo = receiptRepo
+	return nil
+}
+
+// "Then" function that will check the receipts are kept only in memory
+func (t *StorageBackendTest) theReceiptsShouldBeKeptInMemory() error {
+	if _, isInMemory := t.receiptRepo.(*repository.InMemoryRepository); !isInMemory {
+		return fmt.Errorf("expected the receipts to be kept in memory but got a %T", t.receiptRepo)
+	}
+	return t.storageDirShouldOnlyHave()
+}
+
+// "Then" function that will check the receipts are kept in memory and written to a log
+func (t *StorageBackendTest) theReceiptsShouldBeKeptInMemoryWithAWriteAheadLog() error {
+	if _, isInMemory := t.receiptRepo.(*repository.InMemoryRepository); !isInMemory {
+		return fmt.Errorf("expected the receipts to be kept in memory but got a %T", t.receiptRepo)
+	}
+	return t.storageDirShouldOnlyHave("log")
+}
+
+// "Then" function that will check the receipts are kept in the SQLite database file
+func (t *StorageBackendTest) theReceiptsShouldBeKeptInASQLiteDatabase() error {
+	if _, isSQL := t.receiptRepo.(*repository.SQLRepository); !isSQL {
+		return fmt.Errorf("expected the receipts to be kept in a SQLite database but got a %T", t.receiptRepo)
+	}
+	if _, err := t.receiptRepo.FindAll(context.Background()); err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(t.storageDir, "receipts.db")); err != nil {
+		return fmt.Errorf("expected the SQLite database file to be created: %w", err)
+	}
+	return nil
+}
+
+// "Then" function that will check creating the receipt repository for the storage settings fails with an error mentioning a message
+func (t *StorageBackendTest) creatingTheReceiptRepositoryFromTheStorageSettingsShouldFailWith(message string) error {
+	receiptRepo, err := repository.NewReceiptRepository(config.LoadStorage(), logger.GetLogger())
+	if err == nil {
+		t.receiptRepo = receiptRepo
+		return fmt.Errorf("expected creating the receipt repository to fail with %q but got a %T", message, receiptRepo)
+	}
+	if !strings.Contains(err.Error(), message) {
+		return fmt.Errorf("expected creating the receipt repository to fail with %q but got %v", message, err)
+	}
+	return nil
+}
+
+// Function to check the storage directory only has the given entries, so a backend didn't write anywhere it wasn't configured to
+func (t *StorageBackendTest) storageDirShouldOnlyHave(names ...string) error {
+	entries, err := os.ReadDir(t.storageDir)
+	if err != nil {
+		return err
+	}
+	entryNames := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		entryNames = append(entryNames, entry.Name())
+	}
+	if found, expected := strings.Join(entryNames, ", "), strings.Join(names, ", "); found != expected {
+		return fmt.Errorf("expected the storage directory to have %q but it has %q", expected, found)
+	}
+	return nil
+}
+
+// Function to close the receipt repository, clear the storage settings and remove the storage directory
+func (t *StorageBackendTest) reset() error {
+	var closeErr error
+	if closer, isCloser := t.receiptRepo.(io.Closer); isCloser {
+		closeErr = closer.Close()
+	}
+	t.receiptRepo = nil
+	for _, setting := range storageSettings {
+		viper.Set(setting, "")
+	}
+	var removeErr error
+	if t.storageDir != "" {
+		removeErr = os.RemoveAll(t.storageDir)
+		t.storageDir = ""
+	}
+	return errors.Join(closeErr, removeErr)
+}
+
+// Initializes the storage backend scenarios, matching their statements with the corresponding handlers
+func InitializeStorageBackendScenario(ctx *godog.ScenarioContext) {
+	test := &StorageBackendTest{}
+
+	ctx.After(func(ctx context.Context, scenario *godog.Scenario, err error) (context.Context, error) {
+		return ctx, test.reset()
+	})
+
+	ctx.Given(`^the storage backend setting is "([^"]*)"$`, test.theStorageBackendSettingIs)
+	ctx.Given(`^the receipt log directory setting is "([^"]*)"$`, test.theReceiptLogDirectorySettingIs)
+	ctx.When(`^the receipt repository is created from the storage settings$`, test.theReceiptRepositoryIsCreatedFromTheStorageSettings)
+	ctx.Then(`^the receipts should be kept in memory$`, test.theReceiptsShouldBeKeptInMemory)
+	ctx.Then(`^the receipts should be kept in memory with a write-ahead log$`, test.theReceiptsShouldBeKeptInMemoryWithAWriteAheadLog)
+	ctx.Then(`^the receipts should be kept in a SQLite database$`, test.theReceiptsShouldBeKeptInASQLiteDatabase)
+	ctx.Then(`^creating the receipt repository from the storage settings should fail with "([^"]*)"$`, test.creatingTheReceiptRepositoryFromTheStorageSettingsShouldFailWith)
+}
@@ -0,0 +1,201 @@
+package integration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/cucumber/godog"
+	"receipt-processor-challenge/pkg/db"
+	"strings"
+)
+
+const (
+	retailerDateIndex = "retailer_date"
+	retailerIndex     = "retailer"
+	dateIndex         = "date"
+)
+
+// an entity the store index tests save, with a retailer and date to index it by
+type purchase struct {
+	id       string
+	retailer string
+	date     string
+}
+
+func (p purchase) ID() string {
+	return p.id
+}
+
+type StoreIndexTest struct {
+	store   *db.Store[purchase]
+	saveErr error
+}
+
+// "Given" function that will create a store of purchases with an ordered index on the retailer and date and an index on the retailer
+func (t *StoreIndexTest) aStoreOfPurchasesIndexedByRetailerAndDate() error {
+	t.store = db.NewStore[purchase]()
+	if err := t.store.AddOrderedIndex(retailerDateIndex, func(p purchase) string {
+		return db.IndexKey(p.retailer, p.date)
+	}); err != nil {
+		return err
+	}
+	return t.store.AddIndex(retailerIndex, func(p purchase) string {
+		return p.retailer
+	})
+}
+
+// "Given" function that will save a purchase to the store
+func (t *StoreIndexTest) purchaseFromOnIsSaved(id string, retailer string, date string) error {
+	_, err := t.store.Save(purchase{id: id, retailer: retailer, date: date})
+	return err
+}
+
+// "When" function that will save a purchase to the store and keep the error
+func (t *StoreIndexTest) purchaseFromOnIsSavedAgain(id string, retailer string, date string) error {
+	_, t.saveErr = t.store.Save(purchase{id: id, retailer: retailer, date: date})
+	return nil
+}
+
+// "When" function that will update the retailer and date of a purchase in the store
+func (t *StoreIndexTest) purchaseIsUpdatedToBeFromOn(id string, retailer string, date string) error {
+	_, err := t.store.Update(purchase{id: id, retailer: retailer, date: date})
+	return err
+}
+
+// "When" function that will delete a purchase from the store
+func (t *StoreIndexTest) purchaseIsDeleted(id string) error {
+	return t.store.DeleteById(id)
+}
+
+// "When" function that will add an ordered index on the date once the store already has purchases in it
+func (t *StoreIndexTest) theStoreIsIndexedByDate() error {
+	return t.store.AddOrderedIndex(dateIndex, func(p purchase) string {
+		return p.date
+	})
+}
+
+// "Then" function that will check the save of a purchase failed
+func (t *StoreIndexTest) theSaveShouldFail() error {
+	if t.saveErr == nil {
+		return fmt.Errorf("expected the save to fail but it succeeded")
+	}
+	return nil
+}
+
+// "Then" function that will check the purchases found in the retailer and date index for a retailer and date
+func (t *StoreIndexTest) thePurchasesFromOnShouldBe(retailer string, date string, ids string) error {
+	purchases, err := t.store.FindByIndex(retailerDateIndex, db.IndexKey(retailer, date))
+	if err != nil {
+		return err
+	}
+	return purchasesShouldBe(purchases, ids)
+}
+
+// "Then" function that will check the purchases found in the retailer index for a retailer
+func (t *StoreIndexTest) thePurchasesFromShouldBe(retailer string, ids string) error {
+	purchases, err := t.store.FindByIndex(retailerIndex, retailer)
+	if err != nil {
+		return err
+	}
+	return purchasesShouldBe(purchases, ids)
+}
+
+// "Then" function that will check the purchases found in a range of the retailer and date index, from the first date up to but not including the last
+func (t *StoreIndexTest) thePurchasesFromBetweenShouldBe(retailer string, fromDate string, beforeDate string, ids string) error {
+	purchases, err := t.store.FindByRange(retailerDateIndex, db.IndexKey(retailer, fromDate), db.IndexKey(retailer, beforeDate))
+	if err != nil {
+		return err
+	}
+	return purchasesShouldBe(purchases, ids)
+}
+
+// "Then" function that will check the purchases found in the retailer and date index from a retailer and date onwards
+func (t *StoreIndexTest) thePurchasesFromOnwardsShouldBe(retailer string, fromDate string, ids string) error {
+	purchases, err := t.store.FindByRange(retailerDateIndex, db.IndexKey(retailer, fromDate), "")
+	if err != nil {
+		return err
+	}
+	return purchasesShouldBe(purchases, ids)
+}
+
+// "Then" function that will check the purchases found in a range of the date index
+func (t *StoreIndexTest) thePurchasesDatedBetweenShouldBe(fromDate string, beforeDate string, ids string) error {
+	purchases, err := t.store.FindByRange(dateIndex, fromDate, beforeDate)
+	if err != nil {
+		return err
+	}
+	return purchasesShouldBe(purchases, ids)
+}
+
+// "Then" function that will check an index that isn't ordered can't be queried for a range
+func (t *StoreIndexTest) queryingARangeOfTheRetailerIndexShouldFail() error {
+	if _, err := t.store.FindByRange(retailerIndex, "A", "Z"); err == nil {
+		return fmt.Errorf("expected querying a range of the %s index to fail", retailerIndex)
+	}
+	return nil
+}
+
+// "Then" function that will check finding, updating or deleting a purchase fails with db.ErrNotFound
+func (t *StoreIndexTest) actingOnPurchaseShouldReportItWasNotFound(action string, id string) error {
+	var err error
+	switch action {
+	case "finding":
+		_, err = t.store.FindById(id)
+	case "updating":
+		_, err = t.store.Update(purchase{id: id, retailer: "Target", date: "2022-01-01"})
+	case "deleting":
+		err = t.store.DeleteById(id)
+	}
+	if !errors.Is(err, db.ErrNotFound) {
+		return fmt.Errorf("expected %s purchase %s to report it was not found but got %v", action, id, err)
+	}
+	return nil
+}
+
+// Function to check the ids of the purchases found in an index, in order, against a comma separated list
+func purchasesShouldBe(purchases []purchase, ids string) error {
+	foundIds := make([]string, 0, len(purchases))
+	for _, p := range purchases {
+		foundIds = append(foundIds, p.id)
+	}
+	if found := strings.Join(foundIds, ", "); found != ids {
+		return fmt.Errorf("expected the purchases %q but got %q", ids, found)
+	}
+	return nil
+}
+
+// "Then" function that will check the purchase dates of the stored receipts from a retailer on or after a date and before another, in order
+func (t *ReceiptRewardsTest) theReceiptsFromBetweenShouldHaveThePurchaseDates(retailer string, fromDate string, beforeDate string, dates string) error {
+	receipts, err := t.receiptRepo.FindByRetailerBetweenDates(context.Background(), retailer, fromDate, beforeDate)
+	if err != nil {
+		return err
+	}
+	foundDates := make([]string, 0, len(receipts))
+	for _, receipt := range receipts {
+		foundDates = append(foundDates, receipt.Receipt().PurchaseDate)
+	}
+	if found := strings.Join(foundDates, ", "); found != dates {
+		return fmt.Errorf("expected receipts with the purchase dates %q but got %q", dates, found)
+	}
+	return nil
+}
+
+// Initializes the store index scenarios, matching their statements with the corresponding handlers
+func InitializeStoreIndexScenario(ctx *godog.ScenarioContext) {
+	test := &StoreIndexTest{}
+
+	ctx.Given(`^a store of purchases indexed by retailer and date$`, test.aStoreOfPurchasesIndexedByRetailerAndDate)
+	ctx.Step(`^purchase "([^"]*)" from "([^"]*)" on "([^"]*)" is saved$`, test.purchaseFromOnIsSaved)
+	ctx.When(`^purchase "([^"]*)" from "([^"]*)" on "([^"]*)" is saved again$`, test.purchaseFromOnIsSavedAgain)
+	ctx.When(`^purchase "([^"]*)" is updated to be from "([^"]*)" on "([^"]*)"$`, test.purchaseIsUpdatedToBeFromOn)
+	ctx.When(`^purchase "([^"]*)" is deleted$`, test.purchaseIsDeleted)
+	ctx.When(`^the store is indexed by date$`, test.theStoreIsIndexedByDate)
+	ctx.Then(`^the save should fail$`, test.theSaveShouldFail)
+	ctx.Then(`^the purchases from "([^"]*)" on "([^"]*)" should be "([^"]*)"$`, test.thePurchasesFromOnShouldBe)
+	ctx.Then(`^the purchases from "([^"]*)" should be "([^"]*)"$`, test.thePurchasesFromShouldBe)
+	ctx.Then(`^the purchases from "([^"]*)" from "([^"]*)" to "([^"]*)" should be "([^"]*)"$`, test.thePurchasesFromBetweenShouldBe)
+	ctx.Then(`^the purchases from "([^"]*)" on "([^"]*)" onwards should be "([^"]*)"$`, test.thePurchasesFromOnwardsShouldBe)
+	ctx.Then(`^the purchases dated from "([^"]*)" to "([^"]*)" should be "([^"]*)"$`, test.thePurchasesDatedBetweenShouldBe)
+	ctx.Then(`^(finding|updating|deleting) purchase "([^"]*)" should report it was not found$`, test.actingOnPurchaseShouldReportItWasNotFound)
+	ctx.Then(`^querying a range of the retailer index should fail$`, test.queryingARangeOfTheRetailerIndexShouldFail)
+}